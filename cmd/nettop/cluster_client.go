@@ -0,0 +1,45 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterClientFromKubeconfig creates a dynamic client for the cluster of the given kubeconfig context.
+// Empty kubeconfig/context values fall back to the standard kubectl defaults ($KUBECONFIG, ~/.kube/config, current context).
+// It also returns the name of the cluster, to be used as the source of the resources it lists.
+func clusterClientFromKubeconfig(kubeconfig, kubeContext string) (dynamic.Interface, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating cluster client: %w", err)
+	}
+
+	clusterName := restConfig.Host
+	if rawConfig, err := clientConfig.RawConfig(); err == nil {
+		contextName := kubeContext
+		if contextName == "" {
+			contextName = rawConfig.CurrentContext
+		}
+		if kubeCtx, ok := rawConfig.Contexts[contextName]; ok && kubeCtx.Cluster != "" {
+			clusterName = kubeCtx.Cluster
+		}
+	}
+	return client, clusterName, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/np-guard/cluster-topology-analyzer/v2/pkg/analyzer"
+)
+
+// explainConnections returns a human-readable report of all the connections from the given source to the given target,
+// and the evidence for each of them
+func explainConnections(conns []*analyzer.Connections, source, target string) string {
+	var sb strings.Builder
+	found := false
+	for _, conn := range conns {
+		if conn.Source == nil || !workloadMatches(conn.Source, source) || !targetMatches(conn, target) {
+			continue
+		}
+		found = true
+		fmt.Fprintf(&sb, "%s -> %s\n", workloadDescription(conn.Source), targetDescription(conn))
+		for i := range conn.Evidence {
+			fmt.Fprintf(&sb, "  - %s\n", evidenceDescription(&conn.Evidence[i]))
+		}
+	}
+	if !found {
+		fmt.Fprintf(&sb, "no connections found from %s to %s\n", source, target)
+	}
+	return sb.String()
+}
+
+// workloadMatches checks whether the given workload is identified by the given name, in the form <name> or <namespace>/<name>
+func workloadMatches(workload *analyzer.Resource, name string) bool {
+	if ns, wlName, found := strings.Cut(name, "/"); found {
+		return workload.Resource.Namespace == ns && workload.Resource.Name == wlName
+	}
+	return workload.Resource.Name == name
+}
+
+// targetMatches checks whether the connection's target is identified by the given name (a workload, an external host or IP block)
+func targetMatches(conn *analyzer.Connections, name string) bool {
+	if conn.ExternalTarget != nil {
+		return conn.ExternalTarget.Host == name || conn.ExternalTarget.IPBlock == name
+	}
+	return workloadMatches(conn.Target, name)
+}
+
+func workloadDescription(workload *analyzer.Resource) string {
+	if workload.Resource.Namespace == "" {
+		return fmt.Sprintf("%s (%s)", workload.Resource.Name, workload.Resource.Kind)
+	}
+	return fmt.Sprintf("%s/%s (%s)", workload.Resource.Namespace, workload.Resource.Name, workload.Resource.Kind)
+}
+
+func targetDescription(conn *analyzer.Connections) string {
+	if conn.ExternalTarget != nil {
+		endpoint := conn.ExternalTarget.Host + conn.ExternalTarget.IPBlock // exactly one of them is set
+		if conn.ExternalTarget.Port > 0 {
+			endpoint = fmt.Sprintf("%s, port %d", endpoint, conn.ExternalTarget.Port)
+		}
+		return fmt.Sprintf("external endpoint %s", endpoint)
+	}
+	return fmt.Sprintf("%s via Service %s", workloadDescription(conn.Target), conn.Link.Resource.Name)
+}
+
+func evidenceDescription(evidence *analyzer.AddressEvidence) string {
+	desc := fmt.Sprintf("%s: %s", evidence.FilePath, evidence.Location)
+	if evidence.Container.Name != "" {
+		desc = fmt.Sprintf("%s: container %s, %s", evidence.FilePath, evidence.Container.Name, evidence.Location)
+	}
+	if evidence.RawValue == "" {
+		return desc + " (value taken from a Secret is not shown)"
+	}
+	return fmt.Sprintf("%s = %q, matched %q", desc, evidence.RawValue, evidence.MatchedAddress)
+}
@@ -7,13 +7,18 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 
 	"gopkg.in/yaml.v3"
+	networking "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/np-guard/cluster-topology-analyzer/v2/pkg/analyzer"
 )
@@ -53,19 +58,37 @@ func yamlMarshalUsingJSON(content interface{}) ([]byte, error) {
 	return buf, nil
 }
 
-func writeContent(outputFile, outputFormat string, content interface{}) error {
-	var buf []byte
-	var err error
-	if outputFormat == yamlFormat {
-		buf, err = yamlMarshalUsingJSON(content)
-	} else {
+// marshalContent converts the content into the given output format.
+// Graph formats (DOT and Mermaid) can only be used for connections.
+func marshalContent(outputFormat string, content interface{}) ([]byte, error) {
+	switch outputFormat {
+	case yamlFormat:
+		return yamlMarshalUsingJSON(content)
+	case dotFormat, mermaidFormat:
+		conns, ok := content.([]*analyzer.Connections)
+		if !ok {
+			return nil, fmt.Errorf("output format %s can only be used for connections", outputFormat)
+		}
+		if outputFormat == dotFormat {
+			return []byte(analyzer.ConnectionsToDOT(conns)), nil
+		}
+		return []byte(analyzer.ConnectionsToMermaid(conns)), nil
+	default:
 		const indent = "    "
-		buf, err = json.MarshalIndent(content, "", indent)
+		return json.MarshalIndent(content, "", indent)
 	}
+}
+
+func writeContent(outputFile, outputFormat string, content interface{}) error {
+	buf, err := marshalContent(outputFormat, content)
 	if err != nil {
 		return err
 	}
+	return writeOutput(outputFile, buf)
+}
 
+// writeOutput writes the given buffer to the output file, or to the standard output if no output file is given
+func writeOutput(outputFile string, buf []byte) error {
 	if outputFile != "" {
 		return writeBufToFile(outputFile, buf)
 	}
@@ -74,6 +97,78 @@ func writeContent(outputFile, outputFormat string, content interface{}) error {
 	return nil
 }
 
+// policyList returns a List of the given NetworkPolicies, followed by the other policies generated by the synthesizer
+// (admin policies, policies in a non-K8s format and Istio AuthorizationPolicies)
+func policyList(netpols []*networking.NetworkPolicy, synth *analyzer.PoliciesSynthesizer) metaV1.List {
+	items := []runtime.RawExtension{}
+	for _, netpol := range netpols {
+		items = append(items, runtime.RawExtension{Object: netpol})
+	}
+	if banp := synth.BaselineAdminNetworkPolicy(); banp != nil {
+		items = append(items, runtime.RawExtension{Object: banp})
+	}
+	for _, anp := range synth.AdminNetworkPolicies() {
+		items = append(items, runtime.RawExtension{Object: anp})
+	}
+	for _, policy := range synth.PolicyObjects() {
+		items = append(items, runtime.RawExtension{Object: policy})
+	}
+	return metaV1.List{
+		TypeMeta: metaV1.TypeMeta{Kind: "List", APIVersion: "v1"},
+		Items:    items,
+	}
+}
+
+// dnsOptions returns the options for the DNS egress rule in the generated policies, based on the arguments
+func dnsOptions(args *inArgs) []analyzer.PoliciesSynthesizerOption {
+	opts := []analyzer.PoliciesSynthesizerOption{}
+	if args.DNSPortSet { // otherwise, the default port (or the port of the DNS pods preset) is used
+		opts = append(opts, analyzer.WithDNSPort(*args.DNSPort))
+	}
+	switch *args.DNSPods {
+	case "":
+	case kubeDNSPods:
+		opts = append(opts, analyzer.WithKubeDNSPods())
+	case openShiftDNSPods:
+		opts = append(opts, analyzer.WithOpenShiftDNSPods())
+	default:
+		namespace, podLabels, _ := parseNamespacedPods("DNS pods", *args.DNSPods, nil) // already validated
+		opts = append(opts, analyzer.WithDNSPods(namespace, podLabels))
+	}
+	if *args.DNSOverTCP {
+		opts = append(opts, analyzer.WithDNSOverTCP())
+	}
+	if *args.NodeLocalDNS != "" {
+		opts = append(opts, analyzer.WithNodeLocalDNS(*args.NodeLocalDNS))
+	}
+	return opts
+}
+
+// ingressOptions returns the options for the ingress rules of exposed workloads in the generated policies, based on the arguments
+func ingressOptions(args *inArgs) []analyzer.PoliciesSynthesizerOption {
+	opts := []analyzer.PoliciesSynthesizerOption{}
+	if slices.Contains(ingressControllers, *args.IngressCtrl) {
+		opts = append(opts, analyzer.WithIngressController(analyzer.IngressController(*args.IngressCtrl)))
+	} else if *args.IngressCtrl != "" {
+		namespace, podLabels, _ := parseNamespacedPods("ingress controller", *args.IngressCtrl, nil) // already validated
+		opts = append(opts, analyzer.WithIngressControllerPeer(namespace, podLabels))
+	}
+	if *args.GatewayPeers {
+		opts = append(opts, analyzer.WithGatewayPeers())
+	}
+	switch *args.PromPods {
+	case "":
+	case kubePrometheusPods:
+		opts = append(opts, analyzer.WithKubePrometheusPods())
+	case openShiftMonitoringPods:
+		opts = append(opts, analyzer.WithOpenShiftMonitoringPods())
+	default:
+		namespace, podLabels, _ := parseNamespacedPods("Prometheus pods", *args.PromPods, nil) // already validated
+		opts = append(opts, analyzer.WithPrometheusPods(namespace, podLabels))
+	}
+	return opts
+}
+
 // returns verbosity level based on the -q and -v switches
 func getVerbosity(args *inArgs) analyzer.Verbosity {
 	verbosity := analyzer.MediumVerbosity
@@ -85,28 +180,98 @@ func getVerbosity(args *inArgs) analyzer.Verbosity {
 	return verbosity
 }
 
+// synthesizePolicies calls the PoliciesSynthesizer API matching the input source specified in the arguments
+func synthesizePolicies(synth *analyzer.PoliciesSynthesizer, args *inArgs) ([]*networking.NetworkPolicy, error) {
+	switch {
+	case *args.HelmChart != "":
+		return synth.PoliciesFromHelmChart(*args.HelmChart)
+	case *args.Kustomization != "":
+		return synth.PoliciesFromKustomization(*args.Kustomization)
+	case *args.Cluster:
+		client, clusterName, err := clusterClientFromKubeconfig(*args.Kubeconfig, *args.KubeContext)
+		if err != nil {
+			return nil, err
+		}
+		return synth.PoliciesFromCluster(context.Background(), client, clusterName)
+	default:
+		return synth.PoliciesFromFolderPaths(args.DirPaths)
+	}
+}
+
+// extractConnections calls the PoliciesSynthesizer API matching the input source specified in the arguments
+func extractConnections(synth *analyzer.PoliciesSynthesizer, args *inArgs) ([]*analyzer.Connections, error) {
+	switch {
+	case *args.HelmChart != "":
+		return synth.ConnectionsFromHelmChart(*args.HelmChart)
+	case *args.Kustomization != "":
+		return synth.ConnectionsFromKustomization(*args.Kustomization)
+	case *args.Cluster:
+		client, clusterName, err := clusterClientFromKubeconfig(*args.Kubeconfig, *args.KubeContext)
+		if err != nil {
+			return nil, err
+		}
+		return synth.ConnectionsFromCluster(context.Background(), client, clusterName)
+	default:
+		return synth.ConnectionsFromFolderPaths(args.DirPaths)
+	}
+}
+
 // Based on the arguments it is given, scans all YAML files,
 // detects all required connection between resources and outputs a json connectivity report
 // (or NetworkPolicies to allow only this connectivity)
 func detectTopology(args *inArgs) error {
 	logger := analyzer.NewDefaultLoggerWithVerbosity(getVerbosity(args))
-	synth := analyzer.NewPoliciesSynthesizer(analyzer.WithLogger(logger), analyzer.WithDNSPort(*args.DNSPort))
+	opts := []analyzer.PoliciesSynthesizerOption{analyzer.WithLogger(logger), analyzer.WithExternalConnections(),
+		analyzer.WithClusterDomain(*args.ClusterDomain), analyzer.WithHelmValuesFiles(args.ValuesFiles),
+		analyzer.WithHelmSetValues(args.SetValues), analyzer.WithHelmReleaseNamespace(*args.ReleaseNs),
+		analyzer.WithNamespace(*args.Namespace), analyzer.WithLabelSelector(*args.LabelSelector)}
+	if *args.ExtEgress {
+		opts = append(opts, analyzer.WithExternalEgress())
+	}
+	if *args.HintsFile != "" {
+		opts = append(opts, analyzer.WithHints(*args.HintsFile))
+	}
+	if *args.Explain || args.ExplainCmd {
+		opts = append(opts, analyzer.WithExplanations())
+	}
+	if *args.AdminPolicies == baselineAdminPolicy || *args.AdminPolicies == allAdminPolicies {
+		opts = append(opts, analyzer.WithBaselineAdminNetworkPolicy())
+	}
+	if *args.AdminPolicies == crossNamespaceAdminPolicies || *args.AdminPolicies == allAdminPolicies {
+		opts = append(opts, analyzer.WithCrossNamespaceAdminNetworkPolicies())
+	}
+	if *args.IstioAuthz {
+		opts = append(opts, analyzer.WithIstioAuthorizationPolicies())
+	}
+	opts = append(opts, dnsOptions(args)...)
+	opts = append(opts, ingressOptions(args)...)
+	opts = append(opts, analyzer.WithPolicyFormat(analyzer.PolicyFormat(*args.PolicyFormat)))
+	synth := analyzer.NewPoliciesSynthesizer(opts...)
 
 	var content interface{}
 	if args.SynthNetpols != nil && *args.SynthNetpols {
-		policies, synthesisErr := synth.PoliciesFromFolderPaths(args.DirPaths)
+		policies, synthesisErr := synthesizePolicies(synth, args)
 		if synthesisErr != nil {
 			logger.Errorf(synthesisErr, "error synthesizing policies")
 			return synthesisErr
 		}
 		content = analyzer.NetpolListFromNetpolSlice(policies)
+		if *args.AdminPolicies != noAdminPolicies || *args.PolicyFormat != string(analyzer.K8sPolicyFormat) || *args.IstioAuthz {
+			content = policyList(policies, synth)
+		}
 	} else {
 		var err error
-		content, err = synth.ConnectionsFromFolderPaths(args.DirPaths)
+		var conns []*analyzer.Connections
+		conns, err = extractConnections(synth, args)
 		if err != nil {
 			logger.Errorf(err, "error extracting connections")
 			return err
 		}
+		if args.ExplainCmd {
+			report := explainConnections(conns, *args.ExplainSource, *args.ExplainTarget)
+			return writeOutput(*args.OutputFile, []byte(report))
+		}
+		content = conns
 	}
 
 	if err := writeContent(*args.OutputFile, *args.OutputFormat, content); err != nil {
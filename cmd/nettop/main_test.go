@@ -164,6 +164,43 @@ var (
 			false,
 			[]string{"acs-security-demos", "expected_netpol_output.yaml"},
 		},
+		{
+			"HelmChart",
+			nil,
+			yamlFormat,
+			true,
+			[]string{"-helm-chart", filepath.Join(testsDir, "helm_chart", "shop"),
+				"-values", filepath.Join(testsDir, "helm_chart", "values-prod.yaml"), "-set", "backend.port=9091", "-release-namespace", "shop"},
+			false,
+			[]string{"helm_chart", "expected_netpol_output.yaml"},
+		},
+		{
+			"HelmChartConnections",
+			nil,
+			jsonFormat,
+			false,
+			[]string{"-helm-chart", filepath.Join(testsDir, "helm_chart", "shop")},
+			false,
+			[]string{"helm_chart", "expected_output.json"},
+		},
+		{
+			"KustomizeOverlay",
+			nil,
+			yamlFormat,
+			true,
+			[]string{"-kustomize", filepath.Join(testsDir, "kustomize", "overlays", "prod")},
+			false,
+			[]string{"kustomize", "expected_netpol_output.yaml"},
+		},
+		{
+			"InitSidecarAndEphemeralContainers",
+			[][]string{{"init_containers"}},
+			jsonFormat,
+			false,
+			nil,
+			false,
+			[]string{"init_containers", "expected_output.json"},
+		},
 		{
 			"HelpFlag",
 			nil,
@@ -209,6 +246,411 @@ var (
 			true,
 			nil,
 		},
+		{
+			"DirPathAndHelmChart",
+			[][]string{{"bookinfo"}},
+			jsonFormat,
+			true,
+			[]string{"-helm-chart", filepath.Join(testsDir, "helm_chart", "shop")},
+			true,
+			nil,
+		},
+		{
+			"HelmChartAndKustomize",
+			nil,
+			jsonFormat,
+			true,
+			[]string{"-helm-chart", filepath.Join(testsDir, "helm_chart", "shop"), "-kustomize", filepath.Join(testsDir, "kustomize")},
+			true,
+			nil,
+		},
+		{
+			"CustomClusterDomain",
+			[][]string{{"cluster_domain"}},
+			yamlFormat,
+			true,
+			[]string{"-cluster-domain", "corp.internal"},
+			false,
+			[]string{"cluster_domain", "expected_netpol_output.yaml"},
+		},
+		{
+			"ExternalEgressConnections",
+			[][]string{{"external_egress"}},
+			jsonFormat,
+			false,
+			[]string{},
+			false,
+			[]string{"external_egress", "expected_output.json"},
+		},
+		{
+			"ExternalEgressNetpols",
+			[][]string{{"external_egress"}},
+			yamlFormat,
+			true,
+			[]string{"-external-egress"},
+			false,
+			[]string{"external_egress", "expected_netpol_output.yaml"},
+		},
+		{
+			"ConnectionsOutputDOT",
+			[][]string{{"onlineboutique", "kubernetes-manifests.yaml"}},
+			dotFormat,
+			false,
+			[]string{"-q"},
+			false,
+			[]string{"onlineboutique", "expected_output.dot"},
+		},
+		{
+			"ConnectionsOutputMermaid",
+			[][]string{{"external_egress"}},
+			mermaidFormat,
+			false,
+			[]string{"-q"},
+			false,
+			[]string{"external_egress", "expected_output.mmd"},
+		},
+		{
+			"GraphFormatWithNetpols",
+			[][]string{{"bookinfo"}},
+			dotFormat,
+			true,
+			nil,
+			true,
+			nil,
+		},
+		{
+			"ConnectionsWithEvidence",
+			[][]string{{"external_egress"}},
+			jsonFormat,
+			false,
+			[]string{"-explain", "-q"},
+			false,
+			[]string{"external_egress", "expected_explained_output.json"},
+		},
+		{
+			"ExplainWithoutTarget",
+			[][]string{{"init_containers"}},
+			jsonFormat,
+			false,
+			[]string{explainCommand, "-source", "app"},
+			true,
+			nil,
+		},
+		{
+			"ExplainWithNetpols",
+			[][]string{{"init_containers"}},
+			jsonFormat,
+			true,
+			[]string{explainCommand, "-source", "app", "-target", "redis"},
+			true,
+			nil,
+		},
+		{
+			"HintsConnections",
+			[][]string{{"hints", "manifests"}},
+			jsonFormat,
+			false,
+			[]string{"-hints", filepath.Join(testsDir, "hints", "hints.yaml")},
+			false,
+			[]string{"hints", "expected_output.json"},
+		},
+		{
+			"HintsNetpols",
+			[][]string{{"hints", "manifests"}},
+			yamlFormat,
+			true,
+			[]string{"-hints", filepath.Join(testsDir, "hints", "hints.yaml")},
+			false,
+			[]string{"hints", "expected_netpol_output.yaml"},
+		},
+		{
+			"BadHintsFile",
+			[][]string{{"hints", "manifests"}},
+			jsonFormat,
+			false,
+			[]string{"-hints", filepath.Join(testsDir, "hints", "bad_hints.yaml")},
+			true,
+			nil,
+		},
+		{
+			"WorkloadAnnotations",
+			[][]string{{"workload_annotations"}},
+			yamlFormat,
+			true,
+			[]string{"-q"},
+			false,
+			[]string{"workload_annotations", "expected_netpol_output.yaml"},
+		},
+		{
+			"AdminPolicies",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-admin-policies", "all"},
+			false,
+			[]string{"cross_namespace", "expected_admin_policies_output.yaml"},
+		},
+		{
+			"AdminPoliciesWithoutNetpols",
+			[][]string{{"cross_namespace"}},
+			jsonFormat,
+			false,
+			[]string{"-admin-policies", "baseline"},
+			true,
+			nil,
+		},
+		{
+			"BadAdminPolicies",
+			[][]string{{"cross_namespace"}},
+			jsonFormat,
+			true,
+			[]string{"-admin-policies", "strict"},
+			true,
+			nil,
+		},
+		{
+			"CiliumPolicies",
+			[][]string{{"external_egress"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-policy-format", "cilium"},
+			false,
+			[]string{"external_egress", "expected_cilium_output.yaml"},
+		},
+		{
+			"CalicoPolicies",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-policy-format", "calico"},
+			false,
+			[]string{"cross_namespace", "expected_calico_output.yaml"},
+		},
+		{
+			"BadPolicyFormat",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-policy-format", "antrea"},
+			true,
+			nil,
+		},
+		{
+			"PolicyFormatWithAdminPolicies",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-policy-format", "cilium", "-admin-policies", "all"},
+			true,
+			nil,
+		},
+		{
+			"IstioAuthorizationPolicies",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-istio-authz"},
+			false,
+			[]string{"cross_namespace", "expected_istio_authz_output.yaml"},
+		},
+		{
+			"IstioAuthorizationPoliciesWithoutNetpols",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			false,
+			[]string{"-istio-authz"},
+			true,
+			nil,
+		},
+		{
+			"DNSPods",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-dns-pods", "kube-dns", "-dns-tcp", "-node-local-dns", "169.254.20.10"},
+			false,
+			[]string{"cross_namespace", "expected_dns_pods_output.yaml"},
+		},
+		{
+			"OpenShiftDNSPods",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-dns-pods", "openshift-dns"},
+			false,
+			[]string{"cross_namespace", "expected_openshift_dns_output.yaml"},
+		},
+		{
+			"BadDNSPods",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-dns-pods", "kube-system"},
+			true,
+			nil,
+		},
+		{
+			"BadNodeLocalDNSAddress",
+			[][]string{{"cross_namespace"}},
+			yamlFormat,
+			true,
+			[]string{"-node-local-dns", "node-local-dns.kube-system"},
+			true,
+			nil,
+		},
+		{
+			"GatewayAPI",
+			[][]string{{"gateway_api"}},
+			yamlFormat,
+			true,
+			[]string{"-q"},
+			false,
+			[]string{"gateway_api", "expected_netpol_output.yaml"},
+		},
+		{
+			"IngressControllerAndGatewayPeers",
+			[][]string{{"gateway_api"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-ingress-controller", "ingress-nginx", "-gateway-peers"},
+			false,
+			[]string{"gateway_api", "expected_ingress_controller_output.yaml"},
+		},
+		{
+			"BadIngressController",
+			[][]string{{"gateway_api"}},
+			yamlFormat,
+			true,
+			[]string{"-ingress-controller", "traefik"},
+			true,
+			nil,
+		},
+		{
+			"IstioGateway",
+			[][]string{{"istio_gateway"}},
+			yamlFormat,
+			true,
+			[]string{"-q"},
+			false,
+			[]string{"istio_gateway", "expected_netpol_output.yaml"},
+		},
+		{
+			"IstioGatewayPeers",
+			[][]string{{"istio_gateway"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-gateway-peers"},
+			false,
+			[]string{"istio_gateway", "expected_gateway_peers_output.yaml"},
+		},
+		{
+			"IstioGatewayPeersCilium",
+			[][]string{{"istio_gateway"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-gateway-peers", "-policy-format", "cilium"},
+			false,
+			[]string{"istio_gateway", "expected_gateway_peers_cilium_output.yaml"},
+		},
+		{
+			"PrometheusMonitors",
+			[][]string{{"prometheus_monitors"}},
+			yamlFormat,
+			true,
+			[]string{"-q"},
+			false,
+			[]string{"prometheus_monitors", "expected_netpol_output.yaml"},
+		},
+		{
+			"PrometheusPods",
+			[][]string{{"prometheus_monitors"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-prometheus-pods", "kube-prometheus"},
+			false,
+			[]string{"prometheus_monitors", "expected_prometheus_pods_output.yaml"},
+		},
+		{
+			"BadPrometheusPods",
+			[][]string{{"prometheus_monitors"}},
+			yamlFormat,
+			true,
+			[]string{"-prometheus-pods", "monitoring"},
+			true,
+			nil,
+		},
+		{
+			"ExternalServices",
+			[][]string{{"external_services"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-external-egress"},
+			false,
+			[]string{"external_services", "expected_netpol_output.yaml"},
+		},
+		{
+			"ExternalServicesCilium",
+			[][]string{{"external_services"}},
+			yamlFormat,
+			true,
+			[]string{"-q", "-policy-format", "cilium"},
+			false,
+			[]string{"external_services", "expected_cilium_output.yaml"},
+		},
+		{
+			"ClusterAndDirPath",
+			[][]string{{"bookinfo"}},
+			jsonFormat,
+			true,
+			[]string{"-cluster"},
+			true,
+			nil,
+		},
+		{
+			"NamespaceWithoutCluster",
+			[][]string{{"bookinfo"}},
+			jsonFormat,
+			true,
+			[]string{"-namespace", "default"},
+			true,
+			nil,
+		},
+		{
+			"ClusterBadKubeconfig",
+			nil,
+			jsonFormat,
+			true,
+			[]string{"-cluster", "-kubeconfig", filepath.Join(testsDir, "no-such-kubeconfig")},
+			true,
+			nil,
+		},
+		{
+			"ValuesWithoutHelmChart",
+			[][]string{{"bookinfo"}},
+			jsonFormat,
+			true,
+			[]string{"-values", filepath.Join(testsDir, "helm_chart", "values-prod.yaml")},
+			true,
+			nil,
+		},
+		{
+			"ReleaseNamespaceWithoutHelmChart",
+			[][]string{{"bookinfo"}},
+			jsonFormat,
+			true,
+			[]string{"-release-namespace", "shop"},
+			true,
+			nil,
+		},
+		{
+			"badHelmChart",
+			nil,
+			jsonFormat,
+			true,
+			[]string{"-helm-chart", filepath.Join(testsDir, "no-such-chart")},
+			true,
+			nil,
+		},
 		{
 			"badDirPathConnections",
 			[][]string{{"no-such-path"}},
@@ -265,6 +707,28 @@ func (td *TestDetails) runTest(t *testing.T) {
 	}
 }
 
+func TestExplainCommand(t *testing.T) {
+	outFileName, err := getTempOutputFile()
+	require.Nil(t, err)
+	defer os.Remove(outFileName)
+
+	dirPath := pathInTestsDir([]string{"init_containers"})
+	err = _main([]string{explainCommand, "-dirpath", dirPath, "-source", "shop/app", "-target", "redis", "-outputfile", outFileName})
+	require.Nil(t, err)
+	lines, err := readLines(outFileName)
+	require.Nil(t, err)
+	require.Len(t, lines, 2)
+	require.Equal(t, "shop/app (Deployment) -> shop/redis (Deployment) via Service redis", lines[0])
+	require.True(t, strings.HasSuffix(lines[1],
+		"app.yaml: container app, ConfigMap app-config, key CACHE_ADDR = \"redis:6379\", matched \"redis:6379\""))
+
+	err = _main([]string{explainCommand, "-dirpath", dirPath, "-source", "app", "-target", "no-such-workload", "-outputfile", outFileName})
+	require.Nil(t, err)
+	lines, err = readLines(outFileName)
+	require.Nil(t, err)
+	require.Equal(t, []string{"no connections found from app to no-such-workload"}, lines)
+}
+
 func TestAll(t *testing.T) {
 	for testIdx := range testCaseScenarios {
 		tc := &testCaseScenarios[testIdx] // rebind tc into this lexical scope to support reentrancy
@@ -290,14 +754,20 @@ func pathInTestsDir(pathElements []string) string {
 }
 
 func getTestArgs(td *TestDetails, outFile string) []string {
-	res := []string{"-outputfile", outFile, "-format", td.outputFormat}
+	res := []string{}
+	extraFlags := td.extraFlags
+	if len(extraFlags) > 0 && extraFlags[0] == explainCommand { // a subcommand must come before all flags
+		res = append(res, explainCommand)
+		extraFlags = extraFlags[1:]
+	}
+	res = append(res, "-outputfile", outFile, "-format", td.outputFormat)
 	if td.synthNetpols {
 		res = append(res, "-netpols")
 	}
 	for idx := range td.dirPath {
 		res = append(res, "-dirpath", pathInTestsDir(td.dirPath[idx]))
 	}
-	res = append(res, td.extraFlags...)
+	res = append(res, extraFlags...)
 	return res
 }
 
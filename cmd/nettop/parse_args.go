@@ -9,6 +9,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/np-guard/cluster-topology-analyzer/v2/pkg/analyzer"
 )
@@ -24,48 +29,258 @@ func (dp *pathList) Set(path string) error {
 	return nil
 }
 
+type stringList []string
+
+func (sl *stringList) String() string {
+	return fmt.Sprintln(*sl)
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
 const (
-	jsonFormat = "json"
-	yamlFormat = "yaml"
+	explainCommand = "explain"
+
+	jsonFormat    = "json"
+	yamlFormat    = "yaml"
+	dotFormat     = "dot"
+	mermaidFormat = "mermaid"
+
+	noAdminPolicies             = "none"
+	baselineAdminPolicy         = "baseline"
+	crossNamespaceAdminPolicies = "cross-namespace"
+	allAdminPolicies            = "all"
+
+	kubeDNSPods      = "kube-dns"
+	openShiftDNSPods = "openshift-dns"
+
+	kubePrometheusPods      = "kube-prometheus"
+	openShiftMonitoringPods = "openshift-monitoring"
 )
 
+var policyFormats = []string{string(analyzer.K8sPolicyFormat), string(analyzer.CiliumPolicyFormat), string(analyzer.CalicoPolicyFormat)}
+
+var ingressControllers = []string{string(analyzer.IngressNginxController), string(analyzer.OpenShiftRouter),
+	string(analyzer.ContourController), string(analyzer.EnvoyGatewayController), string(analyzer.IstioIngressGateway)}
+
 type inArgs struct {
-	DirPaths     pathList
-	OutputFile   *string
-	OutputFormat *string
-	DNSPort      *int
-	SynthNetpols *bool
-	Quiet        *bool
-	Verbose      *bool
+	DirPaths      pathList
+	HelmChart     *string
+	Kustomization *string
+	Cluster       *bool
+	Kubeconfig    *string
+	KubeContext   *string
+	Namespace     *string
+	LabelSelector *string
+	ValuesFiles   pathList
+	SetValues     stringList
+	ReleaseNs     *string
+	OutputFile    *string
+	OutputFormat  *string
+	DNSPort       *int
+	DNSPortSet    bool // -dnsport was specified, so it overrides the port of the DNS pods preset
+	DNSPods       *string
+	DNSOverTCP    *bool
+	NodeLocalDNS  *string
+	IngressCtrl   *string
+	GatewayPeers  *bool
+	PromPods      *string
+	ClusterDomain *string
+	ExtEgress     *bool
+	HintsFile     *string
+	Explain       *bool
+	ExplainCmd    bool // running the explain subcommand
+	ExplainSource *string
+	ExplainTarget *string
+	SynthNetpols  *bool
+	AdminPolicies *string
+	PolicyFormat  *string
+	IstioAuthz    *bool
+	Quiet         *bool
+	Verbose       *bool
 }
 
 func parseInArgs(cmdlineArgs []string) (*inArgs, error) {
 	args := inArgs{}
-	flagset := flag.NewFlagSet("cluster-topology-analyzer", flag.ContinueOnError)
+	flagsetName := "cluster-topology-analyzer"
+	if len(cmdlineArgs) > 0 && cmdlineArgs[0] == explainCommand {
+		args.ExplainCmd = true
+		flagsetName += " " + explainCommand
+		cmdlineArgs = cmdlineArgs[1:]
+	}
+	flagset := flag.NewFlagSet(flagsetName, flag.ContinueOnError)
 	flagset.Var(&args.DirPaths, "dirpath", "input directory path")
+	args.HelmChart = flagset.String("helm-chart", "", "path to a local Helm chart (directory or archive) to render and analyze")
+	flagset.Var(&args.ValuesFiles, "values", "values file to use when rendering the Helm chart (can be specified multiple times)")
+	flagset.Var(&args.SetValues, "set", "set values when rendering the Helm chart (can be specified multiple times)")
+	args.ReleaseNs = flagset.String("release-namespace", "", "the release namespace to use when rendering the Helm chart (default: default)")
+	args.Kustomization = flagset.String("kustomize", "", "path to a kustomization directory to build and analyze")
+	args.Cluster = flagset.Bool("cluster", false, "analyze the resources of a live cluster instead of YAML files")
+	args.Kubeconfig = flagset.String("kubeconfig", "", "path to the kubeconfig file to use with -cluster (default: standard kubectl lookup)")
+	args.KubeContext = flagset.String("context", "", "the kubeconfig context to use with -cluster (default: current context)")
+	args.Namespace = flagset.String("namespace", "", "only analyze resources in this namespace (with -cluster; default: all namespaces)")
+	args.LabelSelector = flagset.String("selector", "", "only analyze workloads matching this label selector (with -cluster)")
 	args.OutputFile = flagset.String("outputfile", "", "file path to store results")
-	args.OutputFormat = flagset.String("format", jsonFormat, "output format; must be either \"json\" or \"yaml\"")
+	args.OutputFormat = flagset.String("format", jsonFormat, "output format; must be one of \"json\", \"yaml\", \"dot\" or \"mermaid\"")
 	args.SynthNetpols = flagset.Bool("netpols", false, "whether to synthesize NetworkPolicies to allow only the discovered connections")
-	args.DNSPort = flagset.Int("dnsport", analyzer.DefaultDNSPort, "DNS port to be used in egress rules of synthesized NetworkPolicies")
+	args.AdminPolicies = flagset.String("admin-policies", noAdminPolicies,
+		"AdminNetworkPolicy API resources to generate with -netpols; must be one of \"none\", \"baseline\" (default-deny), "+
+			"\"cross-namespace\" (allow cross-namespace connections) or \"all\"")
+	args.PolicyFormat = flagset.String("policy-format", string(analyzer.K8sPolicyFormat),
+		"kind of policies to generate with -netpols; must be one of \"k8s\" (NetworkPolicies), \"cilium\" or \"calico\"")
+	args.IstioAuthz = flagset.Bool("istio-authz", false,
+		"with -netpols, also generate Istio AuthorizationPolicies allowing only the discovered callers (by service account)")
+	args.DNSPort = flagset.Int("dnsport", analyzer.DefaultDNSPort,
+		"DNS port to be used in egress rules of synthesized NetworkPolicies (5353 with -dns-pods openshift-dns)")
+	args.DNSPods = flagset.String("dns-pods", "",
+		"only allow DNS egress to these pods; must be one of \"kube-dns\", \"openshift-dns\" or <namespace>/<label>=<value>[,...] "+
+			"(default: any pod in the cluster)")
+	args.DNSOverTCP = flagset.Bool("dns-tcp", false, "also allow DNS egress over TCP")
+	args.NodeLocalDNS = flagset.String("node-local-dns", "",
+		"also allow DNS egress to this NodeLocal DNSCache IP address (usually "+analyzer.DefaultNodeLocalDNSAddress+")")
+	args.IngressCtrl = flagset.String("ingress-controller", "",
+		"only allow ingress to workloads exposed by Ingress, Route, Gateway API routes and Istio VirtualServices from the pods of "+
+			"this ingress controller; "+
+			"must be one of \""+strings.Join(ingressControllers, "\", \"")+"\" or <namespace>/<label>=<value>[,...] "+
+			"(default: any pod in the cluster)")
+	args.GatewayPeers = flagset.Bool("gateway-peers", false,
+		"only allow ingress to workloads exposed by Gateway API routes and Istio VirtualServices from the pods of the Gateways "+
+			"they are attached to")
+	args.PromPods = flagset.String("prometheus-pods", "",
+		"only allow ingress to ports scraped by Prometheus (ServiceMonitors, PodMonitors and scrape annotations) from these pods; "+
+			"must be one of \"kube-prometheus\", \"openshift-monitoring\" or <namespace>/<label>=<value>[,...] "+
+			"(default: any pod in the cluster)")
+	args.ClusterDomain = flagset.String("cluster-domain", analyzer.DefaultClusterDomain, "DNS domain of the analyzed cluster")
+	args.ExtEgress = flagset.Bool("external-egress", false,
+		"allow egress to any address on the ports used for connecting to external hosts (NetworkPolicies cannot refer to host names)")
+	args.HintsFile = flagset.String("hints", "", "path to a YAML file with hints for adding, suppressing and exposing connections")
+	args.Explain = flagset.Bool("explain", false, "add to each connection the evidence for it (where the target's address was found)")
+	args.ExplainSource = new(string)
+	args.ExplainTarget = new(string)
+	if args.ExplainCmd {
+		args.ExplainSource = flagset.String("source", "", "the source workload of the connections to explain, as <name> or <namespace>/<name>")
+		args.ExplainTarget = flagset.String("target", "",
+			"the target of the connections to explain: a workload (<name> or <namespace>/<name>), or an external host or IP block")
+	}
 	args.Quiet = flagset.Bool("q", false, "runs quietly, reports only severe errors and results")
 	args.Verbose = flagset.Bool("v", false, "runs with more informative messages printed to log")
 	err := flagset.Parse(cmdlineArgs)
 	if err != nil {
 		return nil, err
 	}
+	flagset.Visit(func(f *flag.Flag) { args.DNSPortSet = args.DNSPortSet || f.Name == "dnsport" })
 
-	if len(args.DirPaths) == 0 {
+	err = validateInArgs(&args)
+	if err != nil {
 		flagset.PrintDefaults()
-		return nil, fmt.Errorf("missing parameter: dirpath")
+		return nil, err
+	}
+
+	return &args, nil
+}
+
+// validateInArgs checks that the parsed arguments are consistent
+func validateInArgs(args *inArgs) error {
+	if numInputSources(args) == 0 {
+		return fmt.Errorf("missing parameter: one of dirpath, helm-chart, kustomize or cluster must be specified")
+	}
+	if numInputSources(args) > 1 {
+		return fmt.Errorf("only one of -dirpath, -helm-chart, -kustomize and -cluster can be specified")
+	}
+	if !*args.Cluster && (*args.Kubeconfig != "" || *args.KubeContext != "" || *args.Namespace != "" || *args.LabelSelector != "") {
+		return fmt.Errorf("-kubeconfig, -context, -namespace and -selector can only be specified together with -cluster")
+	}
+	if *args.HelmChart == "" && (len(args.ValuesFiles) > 0 || len(args.SetValues) > 0 || *args.ReleaseNs != "") {
+		return fmt.Errorf("-values, -set and -release-namespace can only be specified together with -helm-chart")
 	}
 	if *args.Quiet && *args.Verbose {
-		flagset.PrintDefaults()
-		return nil, fmt.Errorf("-q and -v cannot be specified together")
+		return fmt.Errorf("-q and -v cannot be specified together")
 	}
-	if *args.OutputFormat != jsonFormat && *args.OutputFormat != yamlFormat {
-		flagset.PrintDefaults()
-		return nil, fmt.Errorf("wrong output format %s; must be either json or yaml", *args.OutputFormat)
+	if !slices.Contains([]string{jsonFormat, yamlFormat, dotFormat, mermaidFormat}, *args.OutputFormat) {
+		return fmt.Errorf("wrong output format %s; must be one of json, yaml, dot or mermaid", *args.OutputFormat)
+	}
+	if args.ExplainCmd && (*args.ExplainSource == "" || *args.ExplainTarget == "") {
+		return fmt.Errorf("missing parameter: both -source and -target must be specified for the %s command", explainCommand)
 	}
+	if args.ExplainCmd && *args.SynthNetpols {
+		return fmt.Errorf("-netpols cannot be specified for the %s command", explainCommand)
+	}
+	if !slices.Contains([]string{noAdminPolicies, baselineAdminPolicy, crossNamespaceAdminPolicies, allAdminPolicies}, *args.AdminPolicies) {
+		return fmt.Errorf("wrong admin policies %s; must be one of none, baseline, cross-namespace or all", *args.AdminPolicies)
+	}
+	if *args.AdminPolicies != noAdminPolicies && !*args.SynthNetpols {
+		return fmt.Errorf("-admin-policies can only be specified together with -netpols")
+	}
+	if !slices.Contains(policyFormats, *args.PolicyFormat) {
+		return fmt.Errorf("wrong policy format %s; must be one of k8s, cilium or calico", *args.PolicyFormat)
+	}
+	if *args.PolicyFormat != string(analyzer.K8sPolicyFormat) && !*args.SynthNetpols {
+		return fmt.Errorf("-policy-format can only be specified together with -netpols")
+	}
+	if *args.PolicyFormat != string(analyzer.K8sPolicyFormat) && *args.AdminPolicies != noAdminPolicies {
+		return fmt.Errorf("-admin-policies can only be specified with the k8s policy format")
+	}
+	if *args.IstioAuthz && !*args.SynthNetpols {
+		return fmt.Errorf("-istio-authz can only be specified together with -netpols")
+	}
+	if _, _, err := parseNamespacedPods("DNS pods", *args.DNSPods, []string{kubeDNSPods, openShiftDNSPods}); err != nil {
+		return err
+	}
+	if _, _, err := parseNamespacedPods("ingress controller", *args.IngressCtrl, ingressControllers); err != nil {
+		return err
+	}
+	prometheusPresets := []string{kubePrometheusPods, openShiftMonitoringPods}
+	if _, _, err := parseNamespacedPods("Prometheus pods", *args.PromPods, prometheusPresets); err != nil {
+		return err
+	}
+	if *args.NodeLocalDNS != "" && net.ParseIP(*args.NodeLocalDNS) == nil {
+		return fmt.Errorf("wrong NodeLocal DNSCache address %s; must be an IP address", *args.NodeLocalDNS)
+	}
+	if *args.SynthNetpols && isGraphFormat(*args.OutputFormat) {
+		return fmt.Errorf("output format %s can only be used for connections, not with -netpols", *args.OutputFormat)
+	}
+	return nil
+}
 
-	return &args, nil
+// parseNamespacedPods returns the namespace and the labels of the pods given in a flag, as <namespace>/<label>=<value>[,...]
+// (nil labels if the flag is not set, or if it names one of the given presets)
+func parseNamespacedPods(what, value string, presets []string) (string, map[string]string, error) {
+	if value == "" || slices.Contains(presets, value) {
+		return "", nil, nil
+	}
+	namespace, selector, found := strings.Cut(value, "/")
+	if !found || namespace == "" || selector == "" {
+		return "", nil, fmt.Errorf("wrong %s %s; must be one of %s or <namespace>/<label>=<value>[,...]",
+			what, value, strings.Join(presets, ", "))
+	}
+	podLabels, err := labels.ConvertSelectorToLabelsMap(selector)
+	if err != nil {
+		return "", nil, fmt.Errorf("wrong %s selector %s: %w", what, selector, err)
+	}
+	return namespace, podLabels, nil
+}
+
+// returns the number of different input sources (dir paths, Helm chart, kustomization, cluster) specified in the arguments
+func numInputSources(args *inArgs) int {
+	res := 0
+	if len(args.DirPaths) > 0 {
+		res++
+	}
+	if *args.HelmChart != "" {
+		res++
+	}
+	if *args.Kustomization != "" {
+		res++
+	}
+	if *args.Cluster {
+		res++
+	}
+	return res
+}
+
+// returns true if the given output format draws the topology as a graph
+func isGraphFormat(outputFormat string) bool {
+	return outputFormat == dotFormat || outputFormat == mermaidFormat
 }
@@ -0,0 +1,127 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+)
+
+// clusterResource describes a K8s resource type which should be listed when scanning a live cluster
+type clusterResource struct {
+	gvr      schema.GroupVersionResource
+	kind     string
+	listKind string
+}
+
+// The resources to list from a live cluster - these are the cluster equivalents of acceptedK8sKinds
+var clusterResources = []clusterResource{
+	{schema.GroupVersionResource{Version: "v1", Resource: "pods"}, pod, "PodList"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "replicationcontrollers"}, replicationController, "ReplicationControllerList"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, replicaSet, "ReplicaSetList"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, deployment, "DeploymentList"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, daemonSet, "DaemonSetList"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, statefulSet, "StatefulSetList"},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, job, "JobList"},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, cronJob, "CronJobList"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "services"}, service, "ServiceList"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, configmap, "ConfigMapList"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, secretKind, "SecretList"},
+	{schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"}, endpointSliceKind,
+		"EndpointSliceList"}, // K8s mirrors the endpoints of selector-less services to EndpointSlices, so Endpoints need not be listed
+	{schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, ingress, "IngressList"},
+	{schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}, route, "RouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}, httpRoute, "HTTPRouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "grpcroutes"}, grpcRoute, "GRPCRouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tcproutes"}, tcpRoute, "TCPRouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tlsroutes"}, tlsRoute, "TLSRouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "udproutes"}, udpRoute, "UDPRouteList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}, gatewayKind, "GatewayList"},
+	{schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "referencegrants"}, referenceGrant,
+		"ReferenceGrantList"},
+	{schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1", Resource: "virtualservices"}, virtualService,
+		"VirtualServiceList"},
+	{schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1", Resource: "gateways"}, gatewayKind, "GatewayList"},
+	{schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors"}, serviceMonitorKind,
+		"ServiceMonitorList"},
+	{schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "podmonitors"}, podMonitorKind,
+		"PodMonitorList"},
+}
+
+// Workload kinds, which are the only kinds filtered by the label selector. Other resources (e.g., Services and ConfigMaps)
+// are used by the selected workloads, though they are usually not labeled like them.
+var clusterWorkloadKinds = []string{pod, replicationController, replicaSet, deployment, daemonSet, statefulSet, job, cronJob}
+
+// Workload kinds which are themselves managed by other workloads (e.g., ReplicaSets managed by Deployments).
+// Objects of these kinds that have a controlling owner are skipped, so each workload is only analyzed once.
+var controlledWorkloadKinds = []string{pod, replicaSet, job}
+
+// clusterScanner is a utility class for listing all relevant K8s resources from a live cluster
+type clusterScanner struct {
+	logger        Logger
+	stopOn1stErr  bool
+	client        dynamic.Interface
+	clusterName   string // used as the source of the resources instead of a file path
+	namespace     string // an empty string means all namespaces
+	labelSelector string // only applied to workloads; an empty string means no filtering by labels
+}
+
+// listInfos lists all relevant resources in the cluster and returns them as Info objects.
+// The Source of each Info object is in the form "<cluster-name>/<namespace>".
+func (cs *clusterScanner) listInfos(ctx context.Context) ([]*resource.Info, []FileProcessingError) {
+	infos := []*resource.Info{}
+	errs := []FileProcessingError{}
+	for _, res := range clusterResources {
+		listOptions := metaV1.ListOptions{}
+		if slices.Contains(clusterWorkloadKinds, res.kind) {
+			listOptions.LabelSelector = cs.labelSelector
+		}
+		objList, err := cs.client.Resource(res.gvr).Namespace(cs.namespace).List(ctx, listOptions)
+		if apierrors.IsNotFound(err) {
+			cs.logger.Debugf("resource %s is not available in cluster %s - skipping", res.gvr.String(), cs.clusterName)
+			continue
+		}
+		if err != nil {
+			// missing permissions (e.g., for listing Secrets) should not fail the analysis - only warn about it
+			severe := !apierrors.IsForbidden(err)
+			errs = appendAndLogNewError(errs, failedListingResources(res.kind, cs.clusterName, err, severe), cs.logger)
+			if stopProcessing(cs.stopOn1stErr, errs) {
+				return nil, errs
+			}
+			continue
+		}
+
+		for idx := range objList.Items {
+			obj := &objList.Items[idx]
+			if slices.Contains(controlledWorkloadKinds, res.kind) && metaV1.GetControllerOf(obj) != nil {
+				continue // this workload is managed by another workload, which we analyze instead
+			}
+			if obj.GetKind() == "" {
+				obj.SetGroupVersionKind(res.gvr.GroupVersion().WithKind(res.kind))
+			}
+			infos = append(infos, cs.infoFromObject(obj))
+		}
+	}
+	return infos, errs
+}
+
+func (cs *clusterScanner) infoFromObject(obj *unstructured.Unstructured) *resource.Info {
+	return &resource.Info{
+		Object:    obj,
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Source:    fmt.Sprintf("%s/%s", cs.clusterName, obj.GetNamespace()),
+	}
+}
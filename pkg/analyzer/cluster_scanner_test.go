@@ -0,0 +1,114 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/np-guard/netpol-analyzer/pkg/manifests/fsscanner"
+)
+
+const testClusterName = "test-cluster"
+
+// newFakeClusterClient returns a fake dynamic client, holding all the resources under the given test dir
+// (resources with no namespace are put in the given namespace), as well as the given extra objects.
+func newFakeClusterClient(t *testing.T, testDir, namespace string, extraObjs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	infos, errs := fsscanner.GetResourceInfosFromDirPath([]string{filepath.Join(getTestsDir(), testDir)}, true, false)
+	require.Empty(t, errs)
+
+	objs := []runtime.Object{}
+	for _, info := range infos {
+		obj, ok := info.Object.(*unstructured.Unstructured)
+		require.True(t, ok)
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+		objs = append(objs, obj)
+	}
+	objs = append(objs, extraObjs...)
+
+	gvrToListKind := map[schema.GroupVersionResource]string{}
+	for _, res := range clusterResources {
+		gvrToListKind[res.gvr] = res.listKind
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind, objs...)
+}
+
+// newOwnedReplicaSet returns a ReplicaSet which is controlled by the Deployment with the given name
+func newOwnedReplicaSet(name, namespace, deploymentName string) *unstructured.Unstructured {
+	rs := &unstructured.Unstructured{}
+	rs.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: replicaSet})
+	rs.SetName(name)
+	rs.SetNamespace(namespace)
+	isController := true
+	rs.SetOwnerReferences([]metaV1.OwnerReference{{APIVersion: "apps/v1", Kind: deployment, Name: deploymentName, Controller: &isController}})
+	return rs
+}
+
+func TestClusterScannerListInfos(t *testing.T) {
+	ownedRS := newOwnedReplicaSet("wordpress-abc123", "default", "wordpress")
+	client := newFakeClusterClient(t, "k8s_wordpress_example", "default", ownedRS)
+	cs := clusterScanner{logger: NewDefaultLogger(), client: client, clusterName: testClusterName}
+	infos, errs := cs.listInfos(context.Background())
+	require.Empty(t, errs)
+	require.Len(t, infos, 4) // 2 deployments + 2 services; the owned ReplicaSet is skipped
+	for _, info := range infos {
+		require.Equal(t, testClusterName+"/default", info.Source)
+	}
+}
+
+func TestClusterScannerNamespaceAndSelector(t *testing.T) {
+	client := newFakeClusterClient(t, "k8s_wordpress_example", "default")
+	cs := clusterScanner{logger: NewDefaultLogger(), client: client, clusterName: testClusterName, namespace: "other"}
+	infos, errs := cs.listInfos(context.Background())
+	require.Empty(t, errs)
+	require.Empty(t, infos)
+
+	cs = clusterScanner{logger: NewDefaultLogger(), client: client, clusterName: testClusterName, labelSelector: "app=wordpress"}
+	infos, errs = cs.listInfos(context.Background())
+	require.Empty(t, errs)
+	require.Len(t, infos, 4)
+
+	cs = clusterScanner{logger: NewDefaultLogger(), client: client, clusterName: testClusterName, labelSelector: "app!=wordpress"}
+	infos, errs = cs.listInfos(context.Background())
+	require.Empty(t, errs)
+	require.Len(t, infos, 2) // the label selector only applies to workloads
+	for _, info := range infos {
+		require.Equal(t, service, info.Object.GetObjectKind().GroupVersionKind().Kind)
+	}
+}
+
+func TestClusterScannerListError(t *testing.T) {
+	client := newFakeClusterClient(t, "k8s_wordpress_example", "default")
+	client.PrependReactor("list", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	cs := clusterScanner{logger: NewDefaultLogger(), client: client, clusterName: testClusterName}
+	infos, errs := cs.listInfos(context.Background())
+	require.Len(t, infos, 2) // only deployments
+	require.Len(t, errs, 1)
+	require.True(t, errs[0].IsSevere())
+	require.Equal(t, testClusterName, errs[0].File())
+	listErr := &FailedListingResourcesError{}
+	require.True(t, errors.As(errs[0].Error(), &listErr))
+
+	cs.stopOn1stErr = true
+	infos, errs = cs.listInfos(context.Background())
+	require.Empty(t, infos)
+	require.Len(t, errs, 1)
+}
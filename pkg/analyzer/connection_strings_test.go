@@ -0,0 +1,42 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkAddressesFromStr(t *testing.T) {
+	valuesToCheck := map[string][]string{
+		"svc:500":                           {"svc:500"},
+		"http://svc:500/something":          {"svc:500"},
+		"kafka-0:9092,kafka-1:9092":         {"kafka-0:9092", "kafka-1:9092"},
+		"kafka-0:9092, kafka-1:9092":        {"kafka-0:9092", "kafka-1:9092"},
+		"--bootstrap=k-0:9092;k-1:9092":     {"k-0:9092", "k-1:9092"},
+		"sentinel-0:26379 sentinel-1:26379": {"sentinel-0:26379", "sentinel-1:26379"},
+		"dev,prod":                          nil, // not all items specify a port
+		"mongodb://mongo-0.mongo:27017,mongo-1.mongo:27017/db?replicaSet=rs0": {"mongo-0.mongo:27017", "mongo-1.mongo:27017"},
+		"mongodb://user:p@ss@mongo-0.mongo,mongo-1.mongo/db":                  {"mongo-0.mongo", "mongo-1.mongo"},
+		"mongodb+srv://mongo.db.svc.cluster.local/app":                        {"mongo.db.svc.cluster.local"},
+		"redis-sentinel://:secret@sentinel-0:26379,sentinel-1:26379/mymaster": {"sentinel-0:26379", "sentinel-1:26379"},
+		"jdbc:postgresql://pg.db:5432/app":                                    {"pg.db:5432"},
+		"jdbc:postgresql://pg-0:5432,pg-1:5432/app?targetServerType=primary":  {"pg-0:5432", "pg-1:5432"},
+		"jdbc:mysql:loadbalance://mysql-0:3306,mysql-1:3306/app":              {"mysql-0:3306", "mysql-1:3306"},
+		"jdbc:sqlserver://mssql:1433;databaseName=app":                        {"mssql:1433"},
+		"jdbc:oracle:thin:@//oracle.db:1521/orclpdb":                          {"oracle.db:1521"},
+		"jdbc:oracle:thin:@oracle:1521:ORCL":                                  {"oracle:1521"},
+		"jdbc:oracle:thin:@(DESCRIPTION=(ADDRESS_LIST=(ADDRESS=(PROTOCOL=TCP)(HOST=ora-0)(PORT=1521))" +
+			"(ADDRESS=(PROTOCOL=TCP)(HOST=ora-1)(PORT=1521)))(CONNECT_DATA=(SERVICE_NAME=orcl)))": {"ora-0:1521", "ora-1:1521"},
+		"SPRING_DATASOURCE_URL=jdbc:postgresql://pg:5432/app": {"pg:5432"},
+	}
+
+	for val, expected := range valuesToCheck {
+		require.Equal(t, expected, networkAddressesFromStr(val), val)
+	}
+}
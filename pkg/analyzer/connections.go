@@ -8,29 +8,54 @@ package analyzer
 
 import (
 	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // This function is at the core of the topology analysis
 // For each resource, it finds other resources that may use it and compiles a list of connections holding these dependencies
-func discoverConnections(resources []*Resource, links []*Service, logger Logger) []*Connections {
+func discoverConnections(resources []*Resource, links []*Service, clusterDomain string, logger Logger) []*Connections {
 	connections := []*Connections{}
 	for _, destRes := range resources {
 		deploymentServices := findServices(destRes, links)
 		logger.Debugf("services matched to %v: %v", destRes.Resource.Name, deploymentServices)
 		for _, svc := range deploymentServices {
-			srcRes := findSource(resources, svc)
+			srcRes := findSource(resources, svc, destRes, clusterDomain)
 			for _, r := range srcRes {
 				if !r.equals(destRes) {
 					logger.Debugf("source: %s target: %s link: %s", r.Resource.Name, destRes.Resource.Name, svc.Resource.Name)
-					connections = append(connections, &Connections{Source: r, Target: destRes, Link: svc})
+					connections = append(connections, &Connections{Source: r, Target: destRes, Link: svc,
+						SourceContainers: r.Resource.usedByContainers, StartupOnly: isStartupOnly(r.Resource.usedByContainers),
+						Evidence: r.Resource.evidence})
 				}
 			}
 			if len(srcRes) == 0 || svcHasExposedPorts(svc) { // found no sources, but some ports need to be exposed
-				connections = append(connections, &Connections{Target: destRes, Link: svc}) // indicates a source-less service
+				// indicates a source-less service
+				connections = append(connections, &Connections{Target: destRes, Link: svc, FromHint: svc.Resource.exposedByHint})
 			}
 		}
 	}
-	return connections
+	connections = append(connections, discoverServiceEgressConnections(resources, links, clusterDomain)...)
+	connections = removeSourcelessServicesWithSources(connections) // services used through ExternalName services now have sources
+	return append(connections, discoverExternalConnections(resources, links, clusterDomain)...)
+}
+
+// isStartupOnly returns true if all the given containers only run during pod startup
+func isStartupOnly(containers []ContainerRef) bool {
+	if len(containers) == 0 {
+		return false
+	}
+	for i := range containers {
+		if !containers[i].isStartupOnly() {
+			return false
+		}
+	}
+	return true
 }
 
 func svcHasExposedPorts(svc *Service) bool {
@@ -61,11 +86,12 @@ func areSelectorsContained(selectors1 map[string]string, selectors2 []string) bo
 	return true
 }
 
-// findServices returns a list of services that may be in front of a given workload resource
+// findServices returns a list of services that may be in front of a given workload resource.
+// Services with no selector (e.g., ExternalName services) are not in front of any workload.
 func findServices(resource *Resource, links []*Service) []*Service {
 	var matchedSvc []*Service
 	for _, link := range links {
-		if link.Resource.Namespace != resource.Resource.Namespace {
+		if link.Resource.Namespace != resource.Resource.Namespace || len(link.Resource.Selectors) == 0 {
 			continue
 		}
 		// all service selector values should be contained in the input selectors of the deployment
@@ -78,20 +104,28 @@ func findServices(resource *Resource, links []*Service) []*Service {
 	return matchedSvc
 }
 
-// findSource returns a list of resources that are likely trying to connect to the given service
-func findSource(resources []*Resource, service *Service) []*Resource {
+// findSource returns a list of resources that are likely trying to connect to the given service (in front of the given target)
+func findSource(resources []*Resource, service *Service, target *Resource, clusterDomain string) []*Resource {
 	tRes := []*Resource{}
 	for _, resource := range resources {
-		serviceAddresses := getPossibleServiceAddresses(service, resource)
-		foundSrc := *resource // We copy the resource so we can specify the ports used by the source found
+		serviceAddresses := getPossibleServiceAddresses(service, resource, clusterDomain)
+		foundSrc := *resource // We copy the resource so we can specify the ports used by the source found (and the evidence)
 		matched := false
-		for _, envVal := range resource.Resource.NetworkAddrs {
-			match, port := envValueMatchesService(envVal, service, serviceAddresses)
+		for _, envVal := range resource.allNetworkAddrs() {
+			match, port := envValueMatchesService(stripPodHostname(envVal, service, target), service, serviceAddresses)
 			if match {
 				matched = true
-				if port.Port > 0 {
+				resource.markMatchedAddr(envVal)
+				isUsedPort := func(used SvcNetworkAttr) bool { return reflect.DeepEqual(used, port) }
+				if port.Port > 0 && !slices.ContainsFunc(foundSrc.Resource.UsedPorts, isUsedPort) {
 					foundSrc.Resource.UsedPorts = append(foundSrc.Resource.UsedPorts, port)
 				}
+				for _, container := range resource.Resource.NetworkAddrContainers[envVal] {
+					if !slices.Contains(foundSrc.Resource.usedByContainers, container) {
+						foundSrc.Resource.usedByContainers = append(foundSrc.Resource.usedByContainers, container)
+					}
+				}
+				foundSrc.Resource.evidence = append(foundSrc.Resource.evidence, resource.evidenceOf(envVal)...)
 			}
 		}
 		if matched {
@@ -101,11 +135,15 @@ func findSource(resources []*Resource, service *Service) []*Resource {
 	return tRes
 }
 
-func getPossibleServiceAddresses(service *Service, resource *Resource) []string {
+// getPossibleServiceAddresses returns all the DNS names through which the given resource may reach the given service.
+// These are the names kube-dns/CoreDNS resolve, given the DNS search path of a pod:
+// "<svc>" (same namespace only), "<svc>.<ns>", "<svc>.<ns>.svc" and "<svc>.<ns>.svc.<cluster-domain>" (with or without a trailing dot).
+func getPossibleServiceAddresses(service *Service, resource *Resource, clusterDomain string) []string {
 	svcAddresses := []string{}
 	if service.Resource.Namespace != "" {
 		serviceDotNamespace := fmt.Sprintf("%s.%s", service.Resource.Name, service.Resource.Namespace)
-		svcAddresses = append(svcAddresses, serviceDotNamespace, serviceDotNamespace+".svc.cluster.local")
+		fqdn := fmt.Sprintf("%s.svc.%s", serviceDotNamespace, clusterDomain)
+		svcAddresses = append(svcAddresses, serviceDotNamespace, serviceDotNamespace+".svc", fqdn, fqdn+".")
 	}
 	if service.Resource.Namespace == resource.Resource.Namespace { // both service and resource live in the same namespace
 		svcAddresses = append(svcAddresses, service.Resource.Name)
@@ -114,6 +152,20 @@ func getPossibleServiceAddresses(service *Service, resource *Resource) []string
 	return svcAddresses
 }
 
+// stripPodHostname removes a leading pod hostname from addresses of individual pods behind a headless service,
+// e.g., "kafka-0.kafka-headless.ns.svc.cluster.local:9092" becomes "kafka-headless.ns.svc.cluster.local:9092".
+// Such DNS names only exist for pods whose subdomain (for StatefulSets, the serviceName) is the headless service.
+func stripPodHostname(envVal string, service *Service, target *Resource) string {
+	if target == nil || !service.Resource.headless || target.Resource.podSubdomain != service.Resource.Name {
+		return envVal
+	}
+	hostname, svcAddress, found := strings.Cut(envVal, ".")
+	if !found || !target.hasPodHostname(hostname) {
+		return envVal
+	}
+	return svcAddress
+}
+
 func envValueMatchesService(envVal string, service *Service, serviceAddresses []string) (bool, SvcNetworkAttr) {
 	// first look for matches without specified port
 	for _, svcAddress := range serviceAddresses {
@@ -131,5 +183,15 @@ func envValueMatchesService(envVal string, service *Service, serviceAddresses []
 			}
 		}
 	}
+
+	// Services declaring no ports (e.g., ExternalName services) may be used with any port
+	if len(service.Resource.Network) == 0 {
+		for _, svcAddress := range serviceAddresses {
+			portStr, found := strings.CutPrefix(envVal, svcAddress+":")
+			if port, err := strconv.Atoi(portStr); found && err == nil && port > 0 {
+				return true, SvcNetworkAttr{Port: port, TargetPort: intstr.FromInt(port), Protocol: corev1.ProtocolTCP}
+			}
+		}
+	}
 	return false, SvcNetworkAttr{}
 }
@@ -40,6 +40,62 @@ type ConfigMapKeyNotFoundError struct {
 	cfgMapName, cfgMapKey, resourceName string
 }
 
+// SecretNotFoundError is the error emitted when a secret is referenced by a workload, but cannot be found.
+// As Secrets are normally not part of the analyzed manifests, this error is only logged at debug level.
+type SecretNotFoundError struct {
+	secretName, resourceName string
+}
+
+// SecretKeyNotFoundError is the error emitted when a secret key is referenced by a workload, but cannot be found
+type SecretKeyNotFoundError struct {
+	secretName, secretKey, resourceName string
+}
+
+// UndeclaredTargetPortError is the error emitted when a service targets a named port which is not declared by any container
+// of a workload it selects
+type UndeclaredTargetPortError struct {
+	serviceName, targetPort, resourceName string
+}
+
+// MalformedAnnotationError is the error emitted when an entry in a workload's topology annotation is malformed
+type MalformedAnnotationError struct {
+	annotation, entry, resourceName string
+}
+
+// MissingReferenceGrantError is the error emitted when a Gateway API route refers to a service in another namespace,
+// but no ReferenceGrant allows it
+type MissingReferenceGrantError struct {
+	routeKind, routeName, serviceName string
+}
+
+// RouteNotAcceptedError is the error emitted when a Gateway API route is attached to a Gateway (or to some of its listeners),
+// but none of these listeners accepts it
+type RouteNotAcceptedError struct {
+	routeKind, routeName, gatewayName string
+}
+
+// MonitorTargetNotFoundError is the error emitted when a ServiceMonitor or a PodMonitor does not scrape any port
+// of the analyzed services or workloads
+type MonitorTargetNotFoundError struct {
+	monitorKind, monitorName string
+}
+
+// AdminPoliciesNotSupportedError is the error emitted when admin policies are requested with a policy format
+// other than K8sPolicyFormat, in which case they are not generated
+type AdminPoliciesNotSupportedError struct {
+	policyFormat PolicyFormat
+}
+
+// FailedLoadingHintsError is the error emitted when the hints file cannot be read or parsed
+type FailedLoadingHintsError struct {
+	origErr error
+}
+
+// HintNotMatchedError is the error emitted when a hint does not match any workload, service or discovered connection
+type HintNotMatchedError struct {
+	hint string
+}
+
 // FailedScanningResource is the error emitted when a known resource cannot be properly deciphered
 type FailedScanningResource struct {
 	resourceType string
@@ -56,6 +112,22 @@ type FailedAccessingDirError struct {
 	origErr error
 }
 
+// FailedRenderingChartError is the error emitted when a Helm chart cannot be loaded or rendered
+type FailedRenderingChartError struct {
+	origErr error
+}
+
+// FailedBuildingKustomizationError is the error emitted when a kustomization cannot be built
+type FailedBuildingKustomizationError struct {
+	origErr error
+}
+
+// FailedListingResourcesError is the error emitted when resources of a given type cannot be listed from a live cluster
+type FailedListingResourcesError struct {
+	resourceType string
+	origErr      error
+}
+
 func (err *NoYamlsFoundError) Error() string {
 	return "no yaml files found"
 }
@@ -72,6 +144,52 @@ func (err *ConfigMapKeyNotFoundError) Error() string {
 	return fmt.Sprintf("configmap %s does not have key %s (referenced by %s)", err.cfgMapName, err.cfgMapKey, err.resourceName)
 }
 
+func (err *SecretNotFoundError) Error() string {
+	return fmt.Sprintf("secret %s not found (referenced by %s)", err.secretName, err.resourceName)
+}
+
+func (err *SecretKeyNotFoundError) Error() string {
+	return fmt.Sprintf("secret %s does not have key %s (referenced by %s)", err.secretName, err.secretKey, err.resourceName)
+}
+
+func (err *UndeclaredTargetPortError) Error() string {
+	return fmt.Sprintf("service %s targets port %s, which is not declared by any container of %s",
+		err.serviceName, err.targetPort, err.resourceName)
+}
+
+func (err *MalformedAnnotationError) Error() string {
+	return fmt.Sprintf("malformed entry %q in annotation %s of %s", err.entry, err.annotation, err.resourceName)
+}
+
+func (err *MissingReferenceGrantError) Error() string {
+	return fmt.Sprintf("%s %s refers to service %s in another namespace, but no ReferenceGrant allows it",
+		err.routeKind, err.routeName, err.serviceName)
+}
+
+func (err *RouteNotAcceptedError) Error() string {
+	return fmt.Sprintf("%s %s is not accepted by Gateway %s", err.routeKind, err.routeName, err.gatewayName)
+}
+
+func (err *MonitorTargetNotFoundError) Error() string {
+	return fmt.Sprintf("%s %s does not scrape any port of the analyzed workloads", err.monitorKind, err.monitorName)
+}
+
+func (err *AdminPoliciesNotSupportedError) Error() string {
+	return fmt.Sprintf("admin policies are not generated with the %s policy format", err.policyFormat)
+}
+
+func (err *FailedLoadingHintsError) Error() string {
+	return fmt.Sprintf("error loading hints file: %v", err.origErr)
+}
+
+func (err *FailedLoadingHintsError) Unwrap() error {
+	return err.origErr
+}
+
+func (err *HintNotMatchedError) Error() string {
+	return fmt.Sprintf("hint did not match anything: %s", err.hint)
+}
+
 func (err *FailedScanningResource) Error() string {
 	return fmt.Sprintf("error scanning %s resource: %v", err.resourceType, err.origErr)
 }
@@ -96,6 +214,30 @@ func (err *FailedAccessingDirError) Unwrap() error {
 	return err.origErr
 }
 
+func (err *FailedRenderingChartError) Error() string {
+	return fmt.Sprintf("error rendering helm chart: %v", err.origErr)
+}
+
+func (err *FailedRenderingChartError) Unwrap() error {
+	return err.origErr
+}
+
+func (err *FailedBuildingKustomizationError) Error() string {
+	return fmt.Sprintf("error building kustomization: %v", err.origErr)
+}
+
+func (err *FailedBuildingKustomizationError) Unwrap() error {
+	return err.origErr
+}
+
+func (err *FailedListingResourcesError) Error() string {
+	return fmt.Sprintf("error listing %s resources: %v", err.resourceType, err.origErr)
+}
+
+func (err *FailedListingResourcesError) Unwrap() error {
+	return err.origErr
+}
+
 // Error returns the actual error
 func (e *FileProcessingError) Error() error {
 	return e.err
@@ -164,6 +306,46 @@ func configMapKeyNotFound(cfgMapName, cfgMapKey, resourceName string) *FileProce
 	return &FileProcessingError{&ConfigMapKeyNotFoundError{cfgMapName, cfgMapKey, resourceName}, "", 0, -1, false, false}
 }
 
+func secretNotFound(secretName, resourceName string) *FileProcessingError {
+	return &FileProcessingError{&SecretNotFoundError{secretName, resourceName}, "", 0, -1, false, false}
+}
+
+func secretKeyNotFound(secretName, secretKey, resourceName string) *FileProcessingError {
+	return &FileProcessingError{&SecretKeyNotFoundError{secretName, secretKey, resourceName}, "", 0, -1, false, false}
+}
+
+func undeclaredTargetPort(serviceName, targetPort, resourceName, filePath string) *FileProcessingError {
+	return &FileProcessingError{&UndeclaredTargetPortError{serviceName, targetPort, resourceName}, filePath, 0, -1, false, false}
+}
+
+func malformedAnnotation(annotation, entry, resourceName, filePath string) *FileProcessingError {
+	return &FileProcessingError{&MalformedAnnotationError{annotation, entry, resourceName}, filePath, 0, -1, false, false}
+}
+
+func missingReferenceGrant(routeKind, routeName, serviceName, filePath string) *FileProcessingError {
+	return &FileProcessingError{&MissingReferenceGrantError{routeKind, routeName, serviceName}, filePath, 0, -1, false, false}
+}
+
+func routeNotAccepted(routeKind, routeName, gatewayName, filePath string) *FileProcessingError {
+	return &FileProcessingError{&RouteNotAcceptedError{routeKind, routeName, gatewayName}, filePath, 0, -1, false, false}
+}
+
+func monitorTargetNotFound(monitorKind, monitorName, filePath string) *FileProcessingError {
+	return &FileProcessingError{&MonitorTargetNotFoundError{monitorKind, monitorName}, filePath, 0, -1, false, false}
+}
+
+func adminPoliciesNotSupported(policyFormat PolicyFormat) *FileProcessingError {
+	return &FileProcessingError{&AdminPoliciesNotSupportedError{policyFormat}, "", 0, -1, false, false}
+}
+
+func failedLoadingHints(hintsFile string, err error) *FileProcessingError {
+	return &FileProcessingError{&FailedLoadingHintsError{err}, hintsFile, 0, -1, true, true}
+}
+
+func hintNotMatched(hint string) *FileProcessingError {
+	return &FileProcessingError{&HintNotMatchedError{hint}, "", 0, -1, false, false}
+}
+
 func failedScanningResource(resourceType, filePath string, err error) *FileProcessingError {
 	return &FileProcessingError{&FailedScanningResource{resourceType, err}, filePath, 0, -1, false, false}
 }
@@ -175,3 +357,15 @@ func failedReadingFile(filePath string, err error) *FileProcessingError {
 func failedAccessingDir(dirPath string, err error, isSubDir bool) *FileProcessingError {
 	return &FileProcessingError{&FailedAccessingDirError{err}, dirPath, 0, -1, !isSubDir, true}
 }
+
+func failedRenderingChart(chartPath string, err error) *FileProcessingError {
+	return &FileProcessingError{&FailedRenderingChartError{err}, chartPath, 0, -1, true, true}
+}
+
+func failedBuildingKustomization(kustomizationDir string, err error) *FileProcessingError {
+	return &FileProcessingError{&FailedBuildingKustomizationError{err}, kustomizationDir, 0, -1, true, true}
+}
+
+func failedListingResources(resourceType, clusterName string, err error, severe bool) *FileProcessingError {
+	return &FileProcessingError{&FailedListingResourcesError{resourceType, err}, clusterName, 0, -1, false, severe}
+}
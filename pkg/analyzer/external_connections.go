@@ -0,0 +1,145 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// discoverExternalConnections returns an "external egress" connection for each external endpoint (IP address, CIDR or
+// external host name) found in the network addresses of the given workloads, which did not match any in-cluster service.
+// CIDRs which configure a list of trusted or excluded peers (see isPeerListCIDR) are ignored.
+// It should only be called after matching all network addresses against all services.
+// Addresses found in Secrets are ignored, as external connections are written to the output.
+func discoverExternalConnections(resources []*Resource, services []*Service, clusterDomain string) []*Connections {
+	namespaces := namespacesOf(resources, services)
+	connections := []*Connections{}
+	for _, res := range resources {
+		resConns := map[ExternalEndpoint]*Connections{}
+		for _, netAddr := range res.Resource.NetworkAddrs {
+			if res.Resource.matchedAddrs[netAddr] {
+				continue
+			}
+			endpoint, ok := externalEndpointFromAddr(netAddr, clusterDomain, namespaces)
+			if !ok || isPeerListCIDR(netAddr, res.evidenceOf(netAddr)) {
+				continue
+			}
+			conn, found := resConns[endpoint]
+			if !found {
+				conn = &Connections{Source: res, ExternalTarget: &endpoint}
+				resConns[endpoint] = conn
+				connections = append(connections, conn)
+			}
+			for _, container := range res.Resource.NetworkAddrContainers[netAddr] {
+				if !slices.Contains(conn.SourceContainers, container) {
+					conn.SourceContainers = append(conn.SourceContainers, container)
+				}
+			}
+			conn.StartupOnly = isStartupOnly(conn.SourceContainers)
+			conn.Evidence = append(conn.Evidence, res.evidenceOf(netAddr)...)
+		}
+	}
+	return connections
+}
+
+// externalEndpointFromAddr checks whether the given network address refers to an endpoint outside the cluster.
+// IP addresses (except for loopback and unspecified addresses) and CIDRs (except for "0.0.0.0/0" and "::/0", which are
+// used for trusting any peer rather than for connecting) are always considered external.
+// Host names are only considered external if they specify a port number and cannot be resolved by the cluster DNS
+// (e.g., "api.stripe.com:443", but not "redis.cache:6379" if "cache" is one of the analyzed namespaces).
+func externalEndpointFromAddr(netAddr, clusterDomain string, namespaces []string) (ExternalEndpoint, bool) {
+	if _, ipNet, err := net.ParseCIDR(netAddr); err == nil {
+		if ones, _ := ipNet.Mask.Size(); ones == 0 {
+			return ExternalEndpoint{}, false
+		}
+		return ExternalEndpoint{IPBlock: ipNet.String()}, true
+	}
+
+	host, portStr, err := net.SplitHostPort(netAddr)
+	if err != nil {
+		host = netAddr // no port specified
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return ExternalEndpoint{}, false // probably a listening address, not a connection
+		}
+		return ExternalEndpoint{IPBlock: hostCIDR(host), Port: port}, true
+	}
+
+	if port == 0 || !isExternalHostName(host, clusterDomain, namespaces) {
+		return ExternalEndpoint{}, false
+	}
+	return ExternalEndpoint{Host: strings.TrimSuffix(host, "."), Port: port}, true
+}
+
+// peerListKeywords are parts of the names of variables and flags holding CIDRs of peers to trust, allow or exclude
+// (e.g., "TRUSTED_PROXIES", "NO_PROXY" or "--allowed-cidrs"), rather than of endpoints to connect to
+var peerListKeywords = []string{"TRUST", "ALLOW", "DENY", "WHITELIST", "BLACKLIST", "BLOCK", "PROXY", "PROXIES", "ACL", "EXCLUDE", "IGNORE"}
+
+// isPeerListCIDR returns true if the given network address is a CIDR, and all the places it was found in are named after
+// a list of peers to trust, allow or exclude: an env var or a ConfigMap key, or a command-line flag ("--<flag>=<CIDR>").
+func isPeerListCIDR(netAddr string, evidence []AddressEvidence) bool {
+	if !strings.Contains(netAddr, "/") || len(evidence) == 0 {
+		return false
+	}
+	for i := range evidence {
+		name := evidenceVariableName(&evidence[i])
+		if !slices.ContainsFunc(peerListKeywords, func(keyword string) bool { return strings.Contains(name, keyword) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// evidenceVariableName returns the (upper-cased) name of the env var, ConfigMap key or flag in which an address was found
+func evidenceVariableName(evidence *AddressEvidence) string {
+	name := ""
+	if envName, found := strings.CutPrefix(evidence.Location, "env "); found {
+		name, _, _ = strings.Cut(envName, ",")
+	} else if idx := strings.LastIndex(evidence.Location, "key "); idx >= 0 {
+		name = evidence.Location[idx+len("key "):]
+	} else if flagName, _, found := strings.Cut(evidence.RawValue, "="); found {
+		name = flagName
+	}
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// isExternalHostName returns true if the given host name is a fully qualified name, which the cluster DNS will not resolve
+func isExternalHostName(host, clusterDomain string, namespaces []string) bool {
+	host = strings.TrimSuffix(host, ".")
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false // a short name - probably a service which is not part of the analyzed resources
+	}
+	tld := labels[len(labels)-1]
+	if slices.Contains(labels, "svc") || slices.Contains(namespaces, tld) || strings.HasSuffix(host, "."+clusterDomain) {
+		return false // an in-cluster name
+	}
+	return !strings.ContainsFunc(tld, func(r rune) bool { return !unicode.IsLetter(r) }) // top-level domains are alphabetic
+}
+
+// namespacesOf returns all the namespaces of the given workloads and services
+func namespacesOf(resources []*Resource, services []*Service) []string {
+	namespaces := []string{}
+	for _, res := range resources {
+		if !slices.Contains(namespaces, res.Resource.Namespace) {
+			namespaces = append(namespaces, res.Resource.Namespace)
+		}
+	}
+	for _, svc := range services {
+		if !slices.Contains(namespaces, svc.Resource.Namespace) {
+			namespaces = append(namespaces, svc.Resource.Namespace)
+		}
+	}
+	return namespaces
+}
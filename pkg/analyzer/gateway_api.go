@@ -0,0 +1,123 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"slices"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// The route kinds each listener protocol supports by default (listeners with other protocols may support any route kind)
+var protocolRouteKinds = map[gatewayv1.ProtocolType][]string{
+	gatewayv1.HTTPProtocolType:  {httpRoute, grpcRoute},
+	gatewayv1.HTTPSProtocolType: {httpRoute, grpcRoute},
+	gatewayv1.TLSProtocolType:   {tlsRoute, tcpRoute},
+	gatewayv1.TCPProtocolType:   {tcpRoute},
+	gatewayv1.UDPProtocolType:   {udpRoute},
+}
+
+// gatewayRoute is a Gateway API route exposing service ports
+type gatewayRoute struct {
+	kind      string
+	name      string
+	namespace string
+	filePath  string
+}
+
+func newGatewayRoute(kind string, meta *metaV1.ObjectMeta, filePath string) *gatewayRoute {
+	return &gatewayRoute{kind: kind, name: meta.Name, namespace: meta.Namespace, filePath: filePath}
+}
+
+// parentGateway returns the full name ("<namespace>/<name>") of the Gateway referenced by the given parentRef of the route
+func (route *gatewayRoute) parentGateway(parentRef *gatewayv1.ParentReference) string {
+	namespace := route.namespace
+	if parentRef.Namespace != nil {
+		namespace = string(*parentRef.Namespace)
+	}
+	return namespace + "/" + string(parentRef.Name)
+}
+
+// gateway holds the parts of a Gateway API Gateway which determine the routes attached to it
+type gateway struct {
+	namespace string
+	name      string
+	listeners []gatewayv1.Listener
+}
+
+func (gw *gateway) fullName() string {
+	return gw.namespace + "/" + gw.name
+}
+
+// accepts returns true if any of the Gateway listeners selected by the given parentRef accepts the given route
+func (gw *gateway) accepts(route *gatewayRoute, parentRef *gatewayv1.ParentReference) bool {
+	for i := range gw.listeners {
+		listener := &gw.listeners[i]
+		if parentRef.SectionName != nil && *parentRef.SectionName != listener.Name {
+			continue
+		}
+		if parentRef.Port != nil && *parentRef.Port != listener.Port {
+			continue
+		}
+		if gw.listenerAllowsRoute(listener, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerAllowsRoute checks the namespace and the kind of the given route against the routes the given listener allows.
+// Routes from namespaces matching a selector are assumed to be allowed, as the labels of namespaces are unknown.
+func (gw *gateway) listenerAllowsRoute(listener *gatewayv1.Listener, route *gatewayRoute) bool {
+	allowedRoutes := listener.AllowedRoutes
+	if allowedRoutes == nil {
+		allowedRoutes = &gatewayv1.AllowedRoutes{}
+	}
+	from := gatewayv1.NamespacesFromSame
+	if allowedRoutes.Namespaces != nil && allowedRoutes.Namespaces.From != nil {
+		from = *allowedRoutes.Namespaces.From
+	}
+	if from == gatewayv1.NamespacesFromSame && route.namespace != gw.namespace {
+		return false
+	}
+
+	kinds := protocolRouteKinds[listener.Protocol]
+	if len(allowedRoutes.Kinds) > 0 {
+		kinds = []string{}
+		for _, kind := range allowedRoutes.Kinds {
+			if kind.Group == nil || string(*kind.Group) == gatewayv1.GroupName {
+				kinds = append(kinds, string(kind.Kind))
+			}
+		}
+	}
+	return kinds == nil || slices.Contains(kinds, route.kind)
+}
+
+// referenceGranted returns true if the given route may refer to the given service, i.e., if they are in the same namespace,
+// or if a ReferenceGrant in the service's namespace allows routes of this kind in the route's namespace to refer to the service
+func referenceGranted(route *gatewayRoute, svcNamespace, svcName string, grants []*gatewayv1beta1.ReferenceGrant) bool {
+	if route.namespace == svcNamespace {
+		return true
+	}
+	for _, grant := range grants {
+		if grant.Namespace != svcNamespace {
+			continue
+		}
+		fromRoute := slices.ContainsFunc(grant.Spec.From, func(from gatewayv1beta1.ReferenceGrantFrom) bool {
+			return string(from.Group) == gatewayv1.GroupName && string(from.Kind) == route.kind && string(from.Namespace) == route.namespace
+		})
+		toService := slices.ContainsFunc(grant.Spec.To, func(to gatewayv1beta1.ReferenceGrantTo) bool {
+			return to.Group == "" && string(to.Kind) == service && (to.Name == nil || string(*to.Name) == svcName)
+		})
+		if fromRoute && toService {
+			return true
+		}
+	}
+	return false
+}
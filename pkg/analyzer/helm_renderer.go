@@ -0,0 +1,138 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/strvals"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	helmReleaseName      = "release-name" // the same release name "helm template" uses by default
+	helmReleaseNamespace = "default"      // the same release namespace "helm template" uses by default
+)
+
+// helmRenderer is a utility class for rendering a local Helm chart into K8s resources, without accessing any cluster
+type helmRenderer struct {
+	logger       Logger
+	stopOn1stErr bool
+	valuesFiles  []string // values files to apply on top of the chart's default values (later files take precedence)
+	setValues    []string // values in the format of helm's --set flag (take precedence over valuesFiles)
+	namespace    string   // the release namespace (.Release.Namespace); empty means "default"
+}
+
+// renderChart renders the templates of the chart in chartPath (a directory or a packaged chart) and returns
+// the rendered resources as Info objects. The Source of each Info is the path of the template which produced it.
+func (hr *helmRenderer) renderChart(chartPath string) ([]*resource.Info, []FileProcessingError) {
+	errs := []FileProcessingError{}
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, appendAndLogNewError(errs, failedRenderingChart(chartPath, err), hr.logger)
+	}
+
+	vals, err := hr.mergeValues()
+	if err != nil {
+		return nil, appendAndLogNewError(errs, failedRenderingChart(chartPath, err), hr.logger)
+	}
+	if err = chartutil.ProcessDependenciesWithMerge(chrt, vals); err != nil {
+		return nil, appendAndLogNewError(errs, failedRenderingChart(chartPath, err), hr.logger)
+	}
+
+	namespace := hr.namespace
+	if namespace == "" {
+		namespace = helmReleaseNamespace
+	}
+	releaseOptions := chartutil.ReleaseOptions{Name: helmReleaseName, Namespace: namespace, IsInstall: true}
+	renderVals, err := chartutil.ToRenderValues(chrt, vals, releaseOptions, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, appendAndLogNewError(errs, failedRenderingChart(chartPath, err), hr.logger)
+	}
+	rendered, err := engine.Render(chrt, renderVals)
+	if err != nil {
+		return nil, appendAndLogNewError(errs, failedRenderingChart(chartPath, err), hr.logger)
+	}
+
+	// sort template names so the order of the resulting resources is deterministic
+	templateNames := make([]string, 0, len(rendered))
+	for name := range rendered {
+		templateNames = append(templateNames, name)
+	}
+	sort.Strings(templateNames)
+
+	infos := []*resource.Info{}
+	for _, name := range templateNames {
+		manifest := rendered[name]
+		if !yamlSuffix.MatchString(name) || strings.TrimSpace(manifest) == "" {
+			continue // skip NOTES.txt, helpers and templates which rendered into nothing
+		}
+		templatePath := templatePathInChart(chrt, chartPath, name)
+		templateInfos, templateErrs := infosFromManifest(manifest, templatePath, hr.stopOn1stErr)
+		for _, err := range templateErrs {
+			errs = appendAndLogNewError(errs, failedReadingFile(templatePath, err), hr.logger)
+			if stopProcessing(hr.stopOn1stErr, errs) {
+				return nil, errs
+			}
+		}
+		infos = append(infos, templateInfos...)
+	}
+	return infos, errs
+}
+
+// mergeValues merges all user-supplied values the same way helm does: values files in order, then --set values
+func (hr *helmRenderer) mergeValues() (map[string]interface{}, error) {
+	vals := map[string]interface{}{}
+	for _, valuesFile := range hr.valuesFiles {
+		fileVals, err := chartutil.ReadValuesFile(valuesFile)
+		if err != nil {
+			return nil, err
+		}
+		vals = chartutil.CoalesceTables(fileVals, vals)
+	}
+	for _, setValue := range hr.setValues {
+		if err := strvals.ParseInto(setValue, vals); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// Rendered templates are named "<chart-name>/templates/..." (or "<chart-name>/charts/<sub-chart>/templates/...").
+// This function translates such a name into a path under chartPath.
+func templatePathInChart(chrt *chart.Chart, chartPath, templateName string) string {
+	relPath := strings.TrimPrefix(templateName, chrt.Name()+"/")
+	return filepath.Join(chartPath, filepath.FromSlash(relPath))
+}
+
+// infosFromManifest parses a string with (possibly multiple) YAML documents into Info objects with the given source
+func infosFromManifest(manifest, source string, stopOnErr bool) ([]*resource.Info, []error) {
+	builder := resource.NewLocalBuilder().
+		Unstructured().
+		Stream(strings.NewReader(manifest), source).
+		Flatten()
+	if !stopOnErr {
+		builder.ContinueOnError()
+	}
+	infos, err := builder.Do().Infos()
+	if err == nil {
+		return infos, nil
+	}
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		return infos, agg.Errors()
+	}
+	return infos, []error{err}
+}
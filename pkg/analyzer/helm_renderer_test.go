@@ -0,0 +1,83 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderChartDefaultValues(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "shop")
+	hr := helmRenderer{logger: NewDefaultLogger()}
+	infos, errs := hr.renderChart(chartPath)
+	require.Empty(t, errs)
+	require.Len(t, infos, 5) // configmap + 2 deployments + 2 services; cache is disabled by default
+	for _, info := range infos {
+		require.Contains(t, info.Source, filepath.Join(chartPath, "templates"))
+	}
+}
+
+func TestRenderChartWithValues(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "shop")
+	valuesFile := filepath.Join(getTestsDir(), "helm_chart", "values-prod.yaml")
+	hr := helmRenderer{logger: NewDefaultLogger(), valuesFiles: []string{valuesFile}, setValues: []string{"backend.port=9091"}}
+	infos, errs := hr.renderChart(chartPath)
+	require.Empty(t, errs)
+	require.Len(t, infos, 7)
+
+	resAcc := newResourceAccumulator(NewDefaultLogger(), false)
+	require.Empty(t, resAcc.parseInfos(infos))
+	require.Len(t, resAcc.workloads, 3)
+	require.Len(t, resAcc.services, 3)
+	for _, svc := range resAcc.services {
+		if svc.Resource.Name == "release-name-shop-backend" {
+			require.Equal(t, 9091, svc.Resource.Network[0].Port)
+			require.Equal(t, filepath.Join(chartPath, "templates", "backend.yaml"), svc.Resource.FilePath)
+		}
+	}
+}
+
+func TestRenderChartReleaseNamespace(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "shop")
+	for _, namespace := range []string{"", "shop"} {
+		hr := helmRenderer{logger: NewDefaultLogger(), namespace: namespace}
+		infos, errs := hr.renderChart(chartPath)
+		require.Empty(t, errs)
+		expectedNamespace := namespace
+		if expectedNamespace == "" {
+			expectedNamespace = helmReleaseNamespace
+		}
+		for _, info := range infos {
+			require.Equal(t, expectedNamespace, info.Namespace)
+		}
+	}
+}
+
+func TestRenderChartBadPath(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "no_such_chart")
+	hr := helmRenderer{logger: NewDefaultLogger()}
+	infos, errs := hr.renderChart(chartPath)
+	require.Empty(t, infos)
+	require.Len(t, errs, 1)
+	require.True(t, errs[0].IsFatal())
+	badChart := &FailedRenderingChartError{}
+	require.True(t, errors.As(errs[0].Error(), &badChart))
+}
+
+func TestRenderChartBadValuesFile(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "shop")
+	hr := helmRenderer{logger: NewDefaultLogger(), valuesFiles: []string{"no_such_values.yaml"}}
+	infos, errs := hr.renderChart(chartPath)
+	require.Empty(t, infos)
+	require.Len(t, errs, 1)
+	badChart := &FailedRenderingChartError{}
+	require.True(t, errors.As(errs[0].Error(), &badChart))
+}
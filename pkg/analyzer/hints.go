@@ -0,0 +1,220 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// connectivityHints are manual corrections to the discovered connectivity, loaded from a YAML hints file (see WithHints()).
+// Workloads and services are referred to as "<name>" (matching all namespaces) or as "<namespace>/<name>".
+type connectivityHints struct {
+	Connections []connectionHint  `yaml:"connections"` // connections to add
+	Suppress    []suppressionHint `yaml:"suppress"`    // discovered connections to remove
+	Expose      []exposureHint    `yaml:"expose"`      // services to expose, as if pointed by an Ingress or a Route
+}
+
+// connectionHint declares that a source workload connects to the workloads behind a service
+type connectionHint struct {
+	Source  string `yaml:"source"`
+	Service string `yaml:"service"` // a service name with no namespace refers to a service in the source's namespace
+	Ports   []int  `yaml:"ports"`   // the service ports used by the source (all the service's ports if empty)
+}
+
+// suppressionHint removes all discovered connections from a source workload to a target workload,
+// or to an external target (given as the external host name or IP block, or as the service it is reached through)
+type suppressionHint struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target"`
+}
+
+// exposureHint exposes a service to the whole cluster, or outside the cluster
+type exposureHint struct {
+	Service    string `yaml:"service"`
+	Ports      []int  `yaml:"ports"`      // the service ports to expose (all the service's ports if empty)
+	Externally bool   `yaml:"externally"` // expose all the service's ports outside the cluster (like a LoadBalancer service)
+}
+
+// loadHints reads and parses the given hints file. Unknown fields are reported as errors, to catch typos in the file.
+func loadHints(hintsFile string) (*connectivityHints, error) {
+	file, err := os.Open(hintsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hints := connectivityHints{}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(&hints)
+	if err != nil && !errors.Is(err, io.EOF) { // an empty hints file is fine
+		return nil, err
+	}
+	return &hints, nil
+}
+
+// exposeServices marks the services in the exposure hints as exposed.
+// It should be called before discovering connections, so exposed services get source-less connections.
+func (hints *connectivityHints) exposeServices(services []*Service, logger Logger) []FileProcessingError {
+	errs := []FileProcessingError{}
+	for i := range hints.Expose {
+		hint := &hints.Expose[i]
+		matched := false
+		for _, svc := range services {
+			if !nameMatches(hint.Service, svc.Resource.Namespace, svc.Resource.Name) {
+				continue
+			}
+			matched = true
+			svc.Resource.exposedByHint = true
+			if hint.Externally {
+				svc.Resource.ExposeExternally = true
+				continue
+			}
+			for j := range svc.Resource.Network {
+				port := &svc.Resource.Network[j]
+				if len(hint.Ports) == 0 || slices.Contains(hint.Ports, port.Port) {
+					port.expose(portExposer{})
+				}
+			}
+		}
+		if !matched {
+			errs = appendAndLogNewError(errs, hintNotMatched(fmt.Sprintf("expose %s", hint.Service)), logger)
+		}
+	}
+	return errs
+}
+
+// applyToConnections adds the connections declared in the hints to the discovered connections (merging duplicate connections),
+// and then removes the suppressed connections
+func (hints *connectivityHints) applyToConnections(connections []*Connections, workloads []*Resource, services []*Service,
+	logger Logger) ([]*Connections, []FileProcessingError) {
+	errs := []FileProcessingError{}
+	for i := range hints.Connections {
+		hint := &hints.Connections[i]
+		hintConns := hint.connections(workloads, services)
+		if len(hintConns) == 0 {
+			errs = appendAndLogNewError(errs, hintNotMatched(fmt.Sprintf("connection from %s to %s", hint.Source, hint.Service)), logger)
+		}
+		for _, hintConn := range hintConns {
+			connections = mergeHintConnection(connections, hintConn)
+		}
+	}
+	connections = removeSourcelessServicesWithSources(connections)
+
+	for i := range hints.Suppress {
+		hint := &hints.Suppress[i]
+		numConns := len(connections)
+		connections = slices.DeleteFunc(connections, hint.matches)
+		if len(connections) == numConns {
+			errs = appendAndLogNewError(errs, hintNotMatched(fmt.Sprintf("suppress %s to %s", hint.Source, hint.Target)), logger)
+		}
+	}
+	return connections, errs
+}
+
+// mergeHintConnection adds the given hint connection to the connections, unless a connection with the same source, target
+// and link already exists. In that case, the ports used by the hint connection are added to the existing connection instead.
+func mergeHintConnection(connections []*Connections, hintConn *Connections) []*Connections {
+	for _, conn := range connections {
+		if conn.Source == nil || conn.Target == nil || conn.Link != hintConn.Link ||
+			!conn.Source.equals(hintConn.Source) || !conn.Target.equals(hintConn.Target) {
+			continue
+		}
+		if len(conn.Source.Resource.UsedPorts) == 0 {
+			return connections // the existing connection already uses all the service ports
+		}
+		mergedSrc := *conn.Source // the source may be shared with other connections
+		mergedSrc.Resource.UsedPorts = slices.Clone(conn.Source.Resource.UsedPorts)
+		for _, port := range hintConn.Source.Resource.UsedPorts {
+			isSamePort := func(used SvcNetworkAttr) bool { return used.Port == port.Port && used.Protocol == port.Protocol }
+			if !slices.ContainsFunc(mergedSrc.Resource.UsedPorts, isSamePort) {
+				mergedSrc.Resource.UsedPorts = append(mergedSrc.Resource.UsedPorts, port)
+			}
+		}
+		conn.Source = &mergedSrc
+		return connections
+	}
+	return append(connections, hintConn)
+}
+
+// removeSourcelessServicesWithSources removes the connections indicating source-less services (which are not exposed),
+// for services which now have sources due to hints
+func removeSourcelessServicesWithSources(connections []*Connections) []*Connections {
+	linksWithSources := map[*Service]bool{}
+	for _, conn := range connections {
+		if conn.Source != nil && conn.Link != nil {
+			linksWithSources[conn.Link] = true
+		}
+	}
+	return slices.DeleteFunc(connections, func(conn *Connections) bool {
+		return conn.Source == nil && conn.Link != nil && linksWithSources[conn.Link] && !svcHasExposedPorts(conn.Link)
+	})
+}
+
+// connections returns a connection from each matching source workload to each workload behind each matching service
+func (hint *connectionHint) connections(workloads []*Resource, services []*Service) []*Connections {
+	conns := []*Connections{}
+	for _, src := range workloads {
+		if !nameMatches(hint.Source, src.Resource.Namespace, src.Resource.Name) {
+			continue
+		}
+		svcName := hint.Service
+		if !strings.Contains(svcName, "/") {
+			svcName = src.Resource.Namespace + "/" + svcName
+		}
+		for _, svc := range services {
+			if !nameMatches(svcName, svc.Resource.Namespace, svc.Resource.Name) {
+				continue
+			}
+			foundSrc := *src // We copy the resource so we can specify the ports used by the source
+			foundSrc.Resource.UsedPorts = nil
+			for _, port := range svc.Resource.Network {
+				if len(hint.Ports) == 0 || slices.Contains(hint.Ports, port.Port) {
+					foundSrc.Resource.UsedPorts = append(foundSrc.Resource.UsedPorts, port)
+				}
+			}
+			if len(foundSrc.Resource.UsedPorts) == 0 {
+				continue // none of the hint's ports is a port of the service
+			}
+			for _, target := range workloads {
+				if !target.equals(src) && slices.Contains(findServices(target, []*Service{svc}), svc) {
+					conns = append(conns, &Connections{Source: &foundSrc, Target: target, Link: svc, FromHint: true})
+				}
+			}
+		}
+	}
+	return conns
+}
+
+// matches checks whether the given connection is suppressed by the hint
+func (hint *suppressionHint) matches(conn *Connections) bool {
+	if conn.Source == nil || !nameMatches(hint.Source, conn.Source.Resource.Namespace, conn.Source.Resource.Name) {
+		return false
+	}
+	if conn.ExternalTarget != nil {
+		if conn.Link != nil && nameMatches(hint.Target, conn.Link.Resource.Namespace, conn.Link.Resource.Name) {
+			return true
+		}
+		return hint.Target == conn.ExternalTarget.Host || hint.Target == conn.ExternalTarget.IPBlock
+	}
+	return conn.Target != nil && nameMatches(hint.Target, conn.Target.Resource.Namespace, conn.Target.Resource.Name)
+}
+
+// nameMatches checks whether the given reference ("<name>" or "<namespace>/<name>") refers to the given resource
+func nameMatches(ref, namespace, name string) bool {
+	if refNs, refName, found := strings.Cut(ref, "/"); found {
+		return refNs == namespace && refName == name
+	}
+	return ref == name
+}
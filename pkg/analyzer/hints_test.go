@@ -0,0 +1,135 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHints(t *testing.T) {
+	hintsDir := filepath.Join(getTestsDir(), "hints")
+	dirPath := filepath.Join(hintsDir, "manifests")
+	synthesizer := NewPoliciesSynthesizer(WithHints(filepath.Join(hintsDir, "hints.yaml")))
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	require.Len(t, conns, 2)
+	for _, conn := range conns { // the connection from orders to docs is suppressed
+		require.True(t, conn.FromHint)
+		if conn.Source == nil {
+			require.Equal(t, "frontend", conn.Link.Resource.Name)
+			require.True(t, conn.Link.Resource.ExposeExternally)
+		} else {
+			require.Equal(t, "frontend", conn.Source.Resource.Name)
+			require.Equal(t, "orders", conn.Link.Resource.Name)
+			require.Len(t, conn.Source.Resource.UsedPorts, 1)
+		}
+	}
+
+	// without hints, only the connection from orders to docs is discovered
+	conns, err = NewPoliciesSynthesizer().ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Len(t, conns, 3) // including source-less frontend and orders
+	for _, conn := range conns {
+		require.False(t, conn.FromHint)
+		if conn.Source != nil {
+			require.Equal(t, "docs", conn.Target.Resource.Name)
+		}
+	}
+}
+
+func TestHintsNotMatched(t *testing.T) {
+	hintsDir := filepath.Join(getTestsDir(), "hints")
+	synthesizer := NewPoliciesSynthesizer(WithHints(filepath.Join(hintsDir, "unmatched_hints.yaml")))
+	_, err := synthesizer.ConnectionsFromFolderPath(filepath.Join(hintsDir, "manifests"))
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	errs := synthesizer.Errors()
+	require.Len(t, errs, 4)
+	for i := range errs {
+		hintErr := &HintNotMatchedError{}
+		require.True(t, errors.As(errs[i].Error(), &hintErr))
+		require.False(t, errs[i].IsSevere())
+	}
+}
+
+func TestBadHintsFile(t *testing.T) {
+	hintsDir := filepath.Join(getTestsDir(), "hints")
+	for _, hintsFile := range []string{"bad_hints.yaml", "no_such_file.yaml"} {
+		synthesizer := NewPoliciesSynthesizer(WithHints(filepath.Join(hintsDir, hintsFile)))
+		_, err := synthesizer.ConnectionsFromFolderPath(filepath.Join(hintsDir, "manifests"))
+		require.NotNil(t, err)
+		hintsErr := &FailedLoadingHintsError{}
+		require.True(t, errors.As(err, &hintsErr))
+	}
+}
+
+func TestDuplicateHints(t *testing.T) {
+	hintsDir := filepath.Join(getTestsDir(), "hints")
+	synthesizer := NewPoliciesSynthesizer(WithHints(filepath.Join(hintsDir, "duplicate_hints.yaml")))
+	conns, err := synthesizer.ConnectionsFromFolderPath(filepath.Join(hintsDir, "manifests"))
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	require.Len(t, conns, 3) // including source-less frontend
+	for _, conn := range conns {
+		if conn.Source == nil {
+			require.Equal(t, "frontend", conn.Link.Resource.Name)
+			continue
+		}
+		if conn.Source.Resource.Name == "orders" {
+			require.False(t, conn.FromHint) // the hint duplicates a discovered connection
+			require.Equal(t, "docs", conn.Target.Resource.Name)
+		} else {
+			require.True(t, conn.FromHint)
+			require.Equal(t, "orders", conn.Target.Resource.Name)
+			require.Len(t, conn.Source.Resource.UsedPorts, 1)
+		}
+	}
+}
+
+func TestHintsSuppressExternalTarget(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "external_egress")
+	hintsFile := filepath.Join(getTestsDir(), "hints", "external_hints.yaml")
+	synthesizer := NewPoliciesSynthesizer(WithHints(hintsFile), WithExternalConnections())
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	externalTargets := []ExternalEndpoint{}
+	for _, conn := range conns {
+		if conn.ExternalTarget != nil {
+			externalTargets = append(externalTargets, *conn.ExternalTarget)
+		}
+	}
+	require.ElementsMatch(t, []ExternalEndpoint{{IPBlock: "2001:db8::20/128", Port: 5432}, {IPBlock: "192.168.100.0/24"}},
+		externalTargets)
+}
+
+func TestHintsSuppressExternalService(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "external_services")
+	hintsFile := filepath.Join(getTestsDir(), "hints", "external_service_hints.yaml")
+	synthesizer := NewPoliciesSynthesizer(WithHints(hintsFile))
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	for _, conn := range conns {
+		if conn.ExternalTarget == nil {
+			continue
+		}
+		if conn.Source.Resource.Name == "api" { // only the connections to the legacy-crm endpoints are left
+			require.Equal(t, "legacy-crm", conn.Link.Resource.Name)
+		} else {
+			require.Equal(t, "db", conn.Link.Resource.Name) // the connection from reports to db is not suppressed
+		}
+	}
+}
@@ -8,7 +8,9 @@ package analyzer
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"unicode"
@@ -17,6 +19,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkv1 "k8s.io/api/networking/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -25,6 +28,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/cli-runtime/pkg/resource"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 const RouteBackendServiceKind = "Service"
@@ -61,6 +66,7 @@ func k8sWorkloadObjectFromInfo(info *resource.Info) (*Resource, error) {
 		obj := parseResourceFromInfo[appsv1.StatefulSet](info)
 		podSpecV1 = &obj.Spec.Template
 		metaObj = obj
+		resourceCtx.Resource.podSubdomain = obj.Spec.ServiceName
 	case cronJob:
 		obj := parseResourceFromInfo[batchv1.CronJob](info)
 		podSpecV1 = &obj.Spec.JobTemplate.Spec.Template
@@ -73,7 +79,7 @@ func k8sWorkloadObjectFromInfo(info *resource.Info) (*Resource, error) {
 		return nil, fmt.Errorf("unsupported object type: `%s`", resourceCtx.Resource.Kind)
 	}
 
-	parseDeployResource(podSpecV1, metaObj, &resourceCtx)
+	resourceCtx.Resource.annotationErrors = parseDeployResource(podSpecV1, metaObj, &resourceCtx)
 	return &resourceCtx, nil
 }
 
@@ -93,7 +99,25 @@ func k8sConfigmapFromInfo(info *resource.Info) (*cfgMap, error) {
 	}
 
 	fullName := obj.Namespace + "/" + obj.Name
-	return &cfgMap{FullName: fullName, Data: obj.Data}, nil
+	return &cfgMap{FullName: fullName, FilePath: info.Source, Data: obj.Data}, nil
+}
+
+// k8sSecretFromInfo creates a secret object from a k8s Secret object, merging its (base64-decoded) data and stringData
+func k8sSecretFromInfo(info *resource.Info) (*secret, error) {
+	obj := parseResourceFromInfo[v1.Secret](info)
+	if obj == nil {
+		return nil, fmt.Errorf("unable to parse secret")
+	}
+
+	data := make(map[string]string, len(obj.Data)+len(obj.StringData))
+	for k, v := range obj.Data {
+		data[k] = string(v)
+	}
+	for k, v := range obj.StringData { // stringData takes precedence over data (same as in the API server)
+		data[k] = v
+	}
+	fullName := obj.Namespace + "/" + obj.Name
+	return &secret{FullName: fullName, FilePath: info.Source, Data: data}, nil
 }
 
 // k8sServiceFromInfo creates a Service object from a k8s Service object
@@ -108,14 +132,21 @@ func k8sServiceFromInfo(info *resource.Info) (*Service, error) {
 	serviceCtx.Resource.Name = svcObj.GetName()
 	serviceCtx.Resource.Namespace = svcObj.Namespace
 	serviceCtx.Resource.Kind = svcObj.Kind
+	serviceCtx.Resource.labels = svcObj.Labels
 	serviceCtx.Resource.Type = svcObj.Spec.Type
 	serviceCtx.Resource.Selectors = matchLabelSelectorToStrLabels(svcObj.Spec.Selector)
 	serviceCtx.Resource.ExposeExternally = (svcObj.Spec.Type == v1.ServiceTypeLoadBalancer || svcObj.Spec.Type == v1.ServiceTypeNodePort)
+	serviceCtx.Resource.headless = svcObj.Spec.ClusterIP == v1.ClusterIPNone
+	if svcObj.Spec.Type == v1.ServiceTypeExternalName {
+		serviceCtx.Resource.ExternalName = svcObj.Spec.ExternalName
+	}
 
 	prometheusPort, prometheusPortValid := exposedPrometheusScrapePort(svcObj.Annotations)
 	for _, p := range svcObj.Spec.Ports {
 		n := SvcNetworkAttr{Port: int(p.Port), TargetPort: p.TargetPort, Protocol: p.Protocol, name: p.Name}
-		n.exposeToCluster = prometheusPortValid && n.equals(prometheusPort)
+		if prometheusPortValid && n.equals(prometheusPort) {
+			n.expose(portExposer{prometheus: true})
+		}
 		serviceCtx.Resource.Network = append(serviceCtx.Resource.Network, n)
 	}
 
@@ -152,9 +183,10 @@ func ocRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
 		return fmt.Errorf("failed to parse Route resource")
 	}
 
-	toExpose.appendPort(routeObj.Namespace, routeObj.Spec.To.Name, &routeObj.Spec.Port.TargetPort)
+	port := exposedPort{port: &routeObj.Spec.Port.TargetPort, exposer: portExposer{ingressController: true}}
+	toExpose.appendPort(routeObj.Namespace, routeObj.Spec.To.Name, &port)
 	for _, backend := range routeObj.Spec.AlternateBackends {
-		toExpose.appendPort(routeObj.Namespace, backend.Name, &routeObj.Spec.Port.TargetPort)
+		toExpose.appendPort(routeObj.Namespace, backend.Name, &port)
 	}
 
 	return nil
@@ -167,10 +199,11 @@ func k8sIngressFromInfo(info *resource.Info, toExpose servicesToExpose) error {
 		return fmt.Errorf("failed to parse Ingress resource")
 	}
 
+	exposer := portExposer{ingressController: true}
 	defaultBackend := ingressObj.Spec.DefaultBackend
 	if defaultBackend != nil && defaultBackend.Service != nil {
-		portToAppend := portFromServiceBackendPort(&defaultBackend.Service.Port)
-		toExpose.appendPort(ingressObj.Namespace, defaultBackend.Service.Name, portToAppend)
+		portToAppend := exposedPort{port: portFromServiceBackendPort(&defaultBackend.Service.Port), exposer: exposer}
+		toExpose.appendPort(ingressObj.Namespace, defaultBackend.Service.Name, &portToAppend)
 	}
 
 	for ruleIdx := range ingressObj.Spec.Rules {
@@ -179,8 +212,8 @@ func k8sIngressFromInfo(info *resource.Info, toExpose servicesToExpose) error {
 			for pathIdx := range rule.HTTP.Paths {
 				svc := rule.HTTP.Paths[pathIdx].Backend.Service
 				if svc != nil {
-					portToAppend := portFromServiceBackendPort(&svc.Port)
-					toExpose.appendPort(ingressObj.Namespace, svc.Name, portToAppend)
+					portToAppend := exposedPort{port: portFromServiceBackendPort(&svc.Port), exposer: exposer}
+					toExpose.appendPort(ingressObj.Namespace, svc.Name, &portToAppend)
 				}
 			}
 		}
@@ -197,101 +230,433 @@ func portFromServiceBackendPort(sbp *networkv1.ServiceBackendPort) *intstr.IntOr
 	return &res
 }
 
+// gatewayHTTPRouteFromInfo updates servicesToExpose based on a Gateway API HTTPRoute object
 func gatewayHTTPRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
 	routeObj := parseResourceFromInfo[gatewayv1.HTTPRoute](info)
 	if routeObj == nil {
 		return fmt.Errorf("failed to parse HTTPRoute resource")
 	}
 
+	route := newGatewayRoute(httpRoute, &routeObj.ObjectMeta, info.Source)
 	for i := range routeObj.Spec.Rules {
 		rule := &routeObj.Spec.Rules[i]
 		for j := range rule.BackendRefs {
-			backend := &rule.BackendRefs[j]
-			if (backend.Kind != nil && string(*backend.Kind) != RouteBackendServiceKind) || backend.Port == nil {
-				continue // ignore backends which are not services and which do not specify a port
-			}
-			namespace := routeObj.Namespace
-			if backend.Namespace != nil {
-				namespace = string(*backend.Namespace)
-			}
-			port := intstr.FromInt32(int32(*backend.Port))
-			toExpose.appendPort(namespace, string(backend.Name), &port)
+			exposeRouteBackend(toExpose, route, routeObj.Spec.ParentRefs, &rule.BackendRefs[j].BackendRef)
 		}
 	}
 
 	return nil
 }
 
+// gatewayGRPCRouteFromInfo updates servicesToExpose based on a Gateway API GRPCRoute object
 func gatewayGRPCRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
 	routeObj := parseResourceFromInfo[gatewayv1.GRPCRoute](info)
 	if routeObj == nil {
 		return fmt.Errorf("failed to parse GRPCRoute resource")
 	}
 
+	route := newGatewayRoute(grpcRoute, &routeObj.ObjectMeta, info.Source)
+	for i := range routeObj.Spec.Rules {
+		rule := &routeObj.Spec.Rules[i]
+		for j := range rule.BackendRefs {
+			exposeRouteBackend(toExpose, route, routeObj.Spec.ParentRefs, &rule.BackendRefs[j].BackendRef)
+		}
+	}
+
+	return nil
+}
+
+// gatewayTCPRouteFromInfo updates servicesToExpose based on a Gateway API TCPRoute object
+func gatewayTCPRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
+	routeObj := parseResourceFromInfo[gatewayv1alpha2.TCPRoute](info)
+	if routeObj == nil {
+		return fmt.Errorf("failed to parse TCPRoute resource")
+	}
+
+	route := newGatewayRoute(tcpRoute, &routeObj.ObjectMeta, info.Source)
+	for i := range routeObj.Spec.Rules {
+		rule := &routeObj.Spec.Rules[i]
+		for j := range rule.BackendRefs {
+			exposeRouteBackend(toExpose, route, routeObj.Spec.ParentRefs, &rule.BackendRefs[j])
+		}
+	}
+
+	return nil
+}
+
+// gatewayTLSRouteFromInfo updates servicesToExpose based on a Gateway API TLSRoute object
+func gatewayTLSRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
+	routeObj := parseResourceFromInfo[gatewayv1alpha2.TLSRoute](info)
+	if routeObj == nil {
+		return fmt.Errorf("failed to parse TLSRoute resource")
+	}
+
+	route := newGatewayRoute(tlsRoute, &routeObj.ObjectMeta, info.Source)
 	for i := range routeObj.Spec.Rules {
 		rule := &routeObj.Spec.Rules[i]
 		for j := range rule.BackendRefs {
-			backend := &rule.BackendRefs[j]
-			if (backend.Kind != nil && string(*backend.Kind) != RouteBackendServiceKind) || backend.Port == nil {
-				continue // ignore backends which are not services and which do not specify a port
+			exposeRouteBackend(toExpose, route, routeObj.Spec.ParentRefs, &rule.BackendRefs[j])
+		}
+	}
+
+	return nil
+}
+
+// gatewayUDPRouteFromInfo updates servicesToExpose based on a Gateway API UDPRoute object
+func gatewayUDPRouteFromInfo(info *resource.Info, toExpose servicesToExpose) error {
+	routeObj := parseResourceFromInfo[gatewayv1alpha2.UDPRoute](info)
+	if routeObj == nil {
+		return fmt.Errorf("failed to parse UDPRoute resource")
+	}
+
+	route := newGatewayRoute(udpRoute, &routeObj.ObjectMeta, info.Source)
+	for i := range routeObj.Spec.Rules {
+		rule := &routeObj.Spec.Rules[i]
+		for j := range rule.BackendRefs {
+			exposeRouteBackend(toExpose, route, routeObj.Spec.ParentRefs, &rule.BackendRefs[j])
+		}
+	}
+
+	return nil
+}
+
+// exposeRouteBackend updates servicesToExpose with the backend of a Gateway API route, exposing it by each Gateway
+// the route is attached to. A route which is not attached to any Gateway is assumed to be exposed by an ingress controller.
+func exposeRouteBackend(toExpose servicesToExpose, route *gatewayRoute, parentRefs []gatewayv1.ParentReference,
+	backend *gatewayv1.BackendRef) {
+	if (backend.Kind != nil && string(*backend.Kind) != RouteBackendServiceKind) || backend.Port == nil {
+		return // ignore backends which are not services and which do not specify a port
+	}
+	namespace := route.namespace
+	if backend.Namespace != nil {
+		namespace = string(*backend.Namespace)
+	}
+	port := intstr.FromInt32(int32(*backend.Port))
+	attached := false
+	for i := range parentRefs {
+		parentRef := &parentRefs[i]
+		if (parentRef.Group != nil && string(*parentRef.Group) != gatewayv1.GroupName) ||
+			(parentRef.Kind != nil && string(*parentRef.Kind) != gatewayKind) {
+			continue // e.g., a Service, for routes configuring a service mesh
+		}
+		attached = true
+		exposer := portExposer{gateway: route.parentGateway(parentRef)}
+		toExpose.appendPort(namespace, string(backend.Name), &exposedPort{&port, exposer, route, parentRef})
+	}
+	if !attached {
+		toExpose.appendPort(namespace, string(backend.Name), &exposedPort{&port, portExposer{ingressController: true}, route, nil})
+	}
+}
+
+// gatewayFromInfo creates a gateway object from a Gateway API Gateway object
+func gatewayFromInfo(info *resource.Info) (*gateway, error) {
+	gatewayObj := parseResourceFromInfo[gatewayv1.Gateway](info)
+	if gatewayObj == nil {
+		return nil, fmt.Errorf("failed to parse Gateway resource")
+	}
+	return &gateway{namespace: gatewayObj.Namespace, name: gatewayObj.Name, listeners: gatewayObj.Spec.Listeners}, nil
+}
+
+// referenceGrantFromInfo parses a Gateway API ReferenceGrant object
+func referenceGrantFromInfo(info *resource.Info) (*gatewayv1beta1.ReferenceGrant, error) {
+	grantObj := parseResourceFromInfo[gatewayv1beta1.ReferenceGrant](info)
+	if grantObj == nil {
+		return nil, fmt.Errorf("failed to parse ReferenceGrant resource")
+	}
+	return grantObj, nil
+}
+
+// istioVirtualServiceFromInfo updates servicesToExpose based on an Istio VirtualService object. Only VirtualServices bound to
+// Istio Gateways expose their destinations, as VirtualServices bound to the "mesh" gateway only route traffic inside the mesh.
+func istioVirtualServiceFromInfo(info *resource.Info, toExpose servicesToExpose) error {
+	vsObj := parseResourceFromInfo[istioVirtualService](info)
+	if vsObj == nil {
+		return fmt.Errorf("failed to parse VirtualService resource")
+	}
+
+	gateways := vsObj.exposingGateways()
+	for _, route := range slices.Concat(vsObj.Spec.HTTP, vsObj.Spec.TCP, vsObj.Spec.TLS) {
+		for i := range route.Route {
+			destination := &route.Route[i].Destination
+			namespace, svcName, ok := istioServiceFromHost(destination.Host, vsObj.Namespace)
+			if !ok {
+				continue
+			}
+			var port *intstr.IntOrString // all service ports
+			if destination.Port != nil {
+				destPort := intstr.FromInt32(destination.Port.Number)
+				port = &destPort
 			}
-			port := intstr.FromInt32(int32(*backend.Port))
-			namespace := routeObj.Namespace
-			if backend.Namespace != nil {
-				namespace = string(*backend.Namespace)
+			for _, gw := range gateways {
+				toExpose.appendPort(namespace, svcName, &exposedPort{port: port, exposer: portExposer{istioGateway: gw}})
 			}
-			toExpose.appendPort(namespace, string(backend.Name), &port)
 		}
 	}
 
 	return nil
 }
 
-func parseDeployResource(podSpec *v1.PodTemplateSpec, obj metaV1.Object, resourceCtx *Resource) {
+// istioGatewayFromInfo parses an Istio Gateway object
+func istioGatewayFromInfo(info *resource.Info) (*istioGateway, error) {
+	gatewayObj := parseResourceFromInfo[istioGateway](info)
+	if gatewayObj == nil {
+		return nil, fmt.Errorf("failed to parse Istio Gateway resource")
+	}
+	return gatewayObj, nil
+}
+
+// serviceMonitorFromInfo parses a prometheus-operator ServiceMonitor object
+func serviceMonitorFromInfo(info *resource.Info) (*serviceMonitor, error) {
+	monitorObj := parseResourceFromInfo[serviceMonitorObject](info)
+	if monitorObj == nil {
+		return nil, fmt.Errorf("failed to parse ServiceMonitor resource")
+	}
+	return &serviceMonitor{*monitorObj, info.Source}, nil
+}
+
+// podMonitorFromInfo parses a prometheus-operator PodMonitor object
+func podMonitorFromInfo(info *resource.Info) (*podMonitor, error) {
+	monitorObj := parseResourceFromInfo[podMonitorObject](info)
+	if monitorObj == nil {
+		return nil, fmt.Errorf("failed to parse PodMonitor resource")
+	}
+	return &podMonitor{*monitorObj, info.Source}, nil
+}
+
+// k8sEndpointSliceFromInfo parses the addresses and the ports of an EndpointSlice object.
+// EndpointSlices which are not labeled with the name of their service are ignored (nil is returned).
+func k8sEndpointSliceFromInfo(info *resource.Info) (*serviceEndpoints, error) {
+	sliceObj := parseResourceFromInfo[discoveryv1.EndpointSlice](info)
+	if sliceObj == nil {
+		return nil, fmt.Errorf("failed to parse EndpointSlice resource")
+	}
+	svcName := sliceObj.Labels[discoveryv1.LabelServiceName]
+	if svcName == "" {
+		return nil, nil
+	}
+
+	eps := &serviceEndpoints{namespace: sliceObj.Namespace, service: svcName}
+	for i := range sliceObj.Endpoints {
+		for _, address := range sliceObj.Endpoints[i].Addresses {
+			eps.addAddress(address)
+		}
+	}
+	for _, port := range sliceObj.Ports {
+		if port.Port == nil {
+			continue // all ports
+		}
+		name := ""
+		if port.Name != nil {
+			name = *port.Name
+		}
+		eps.addPort(endpointPort{name: name, port: int(*port.Port)})
+	}
+	return eps, nil
+}
+
+// k8sEndpointsFromInfo parses the addresses and the ports of an Endpoints object (named after its service)
+func k8sEndpointsFromInfo(info *resource.Info) (*serviceEndpoints, error) {
+	endpointsObj := parseResourceFromInfo[v1.Endpoints](info) //nolint:staticcheck // deprecated, but still used with selector-less services
+	if endpointsObj == nil {
+		return nil, fmt.Errorf("failed to parse Endpoints resource")
+	}
+
+	eps := &serviceEndpoints{namespace: endpointsObj.Namespace, service: endpointsObj.Name}
+	for i := range endpointsObj.Subsets {
+		subset := &endpointsObj.Subsets[i]
+		for j := range subset.Addresses {
+			eps.addAddress(subset.Addresses[j].IP)
+		}
+		for _, port := range subset.Ports {
+			eps.addPort(endpointPort{name: port.Name, port: int(port.Port)})
+		}
+	}
+	return eps, nil
+}
+
+// parseDeployResource fills the given resource from the given object and its pod template.
+// It returns errors for malformed workload annotations (see DependsOnAnnotation and IgnoreEnvAnnotation).
+func parseDeployResource(podSpec *v1.PodTemplateSpec, obj metaV1.Object, resourceCtx *Resource) []*FileProcessingError {
 	resourceCtx.Resource.Name = obj.GetName()
 	resourceCtx.Resource.Namespace = obj.GetNamespace()
 	resourceCtx.Resource.Labels = podSpec.Labels
 	delete(resourceCtx.Resource.Labels, "pod-template-hash") // auto-generated - better not use it in netpols
 	resourceCtx.Resource.ServiceAccountName = podSpec.Spec.ServiceAccountName
+	resourceCtx.Resource.podHostname = podSpec.Spec.Hostname
+	if podSpec.Spec.Subdomain != "" {
+		resourceCtx.Resource.podSubdomain = podSpec.Spec.Subdomain
+	}
+	dependencies, annotationErrs := parseTopologyAnnotations(podSpec, obj, resourceCtx)
+	addDependencies(dependencies, resourceCtx)
 	for containerIdx := range podSpec.Spec.Containers {
 		container := &podSpec.Spec.Containers[containerIdx]
 		resourceCtx.Resource.Image.ID = container.Image
-		for _, e := range container.Env {
-			if e.Value != "" {
-				if netAddr, ok := networkAddressFromStr(e.Value); ok {
-					resourceCtx.Resource.NetworkAddrs = append(resourceCtx.Resource.NetworkAddrs, netAddr)
-				}
-			} else if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil {
-				keyRef := e.ValueFrom.ConfigMapKeyRef
-				if keyRef.Name != "" && keyRef.Key != "" { // just store ref for now - check later if it's a network address
-					cfgMapKeyRef := cfgMapKeyRef{Name: keyRef.Name, Key: keyRef.Key}
-					resourceCtx.Resource.ConfigMapKeyRefs = append(resourceCtx.Resource.ConfigMapKeyRefs, cfgMapKeyRef)
-				}
-			}
-		}
-		for _, envFrom := range container.EnvFrom {
-			if envFrom.ConfigMapRef != nil { // just store ref for now - check later if the config map values contain a network address
-				resourceCtx.Resource.ConfigMapRefs = append(resourceCtx.Resource.ConfigMapRefs, envFrom.ConfigMapRef.Name)
-			}
-		}
-		resourceCtx.Resource.NetworkAddrs = appendNetworkAddresses(resourceCtx.Resource.NetworkAddrs, container.Args)
-		resourceCtx.Resource.NetworkAddrs = appendNetworkAddresses(resourceCtx.Resource.NetworkAddrs, container.Command)
+		parseContainer(container, ContainerRef{Name: container.Name, Type: AppContainer}, resourceCtx)
+	}
+	for containerIdx := range podSpec.Spec.InitContainers {
+		container := &podSpec.Spec.InitContainers[containerIdx]
+		parseContainer(container, ContainerRef{Name: container.Name, Type: initContainerType(container)}, resourceCtx)
+	}
+	for containerIdx := range podSpec.Spec.EphemeralContainers {
+		container := (*v1.Container)(&podSpec.Spec.EphemeralContainers[containerIdx].EphemeralContainerCommon)
+		parseContainer(container, ContainerRef{Name: container.Name, Type: EphemeralContainer}, resourceCtx)
 	}
 	for volIdx := range podSpec.Spec.Volumes {
 		volume := &podSpec.Spec.Volumes[volIdx]
 		if volume.ConfigMap != nil {
-			resourceCtx.Resource.ConfigMapRefs = append(resourceCtx.Resource.ConfigMapRefs, volume.ConfigMap.Name)
+			cfgMapRef := cfgMapRef{Name: volume.ConfigMap.Name, Containers: containersMountingVolume(&podSpec.Spec, volume.Name),
+				optional: isOptional(volume.ConfigMap.Optional)}
+			resourceCtx.Resource.ConfigMapRefs = append(resourceCtx.Resource.ConfigMapRefs, cfgMapRef)
+		}
+		if volume.Secret != nil && volume.Secret.SecretName != "" {
+			secretRef := cfgMapRef{Name: volume.Secret.SecretName, Containers: containersMountingVolume(&podSpec.Spec, volume.Name),
+				optional: isOptional(volume.Secret.Optional)}
+			resourceCtx.Resource.SecretRefs = append(resourceCtx.Resource.SecretRefs, secretRef)
+		}
+	}
+	return annotationErrs
+}
+
+// parseContainer looks for network addresses and ConfigMap references in the given container's envs, args and command
+func parseContainer(container *v1.Container, containerRef ContainerRef, resourceCtx *Resource) {
+	for _, e := range container.Env {
+		if hasVarRef(e.Value) || resourceCtx.isIgnoredEnv(e.Name) {
+			continue // handled after expanding the variables it refers to, or should not be scanned
+		}
+		if e.Value != "" {
+			for _, netAddr := range networkAddressesFromStr(e.Value) {
+				resourceCtx.addNetworkAddr(netAddr, AddressEvidence{Container: containerRef, Location: envLocation(e.Name), RawValue: e.Value})
+			}
+		} else if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil {
+			keyRef := e.ValueFrom.ConfigMapKeyRef
+			if keyRef.Name != "" && keyRef.Key != "" { // just store ref for now - check later if it's a network address
+				cfgMapKeyRef := cfgMapKeyRef{Name: keyRef.Name, Key: keyRef.Key, EnvName: e.Name, Container: containerRef,
+					optional: isOptional(keyRef.Optional)}
+				resourceCtx.Resource.ConfigMapKeyRefs = append(resourceCtx.Resource.ConfigMapKeyRefs, cfgMapKeyRef)
+			}
+		} else if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+			keyRef := e.ValueFrom.SecretKeyRef
+			if keyRef.Name != "" && keyRef.Key != "" { // just store ref for now - check later if it's a network address
+				secretKeyRef := cfgMapKeyRef{Name: keyRef.Name, Key: keyRef.Key, EnvName: e.Name, Container: containerRef,
+					optional: isOptional(keyRef.Optional)}
+				resourceCtx.Resource.SecretKeyRefs = append(resourceCtx.Resource.SecretKeyRefs, secretKeyRef)
+			}
+		}
+	}
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.ConfigMapRef != nil { // just store ref for now - check later if the config map values contain a network address
+			cfgMapRef := cfgMapRef{Name: envFrom.ConfigMapRef.Name, Containers: []ContainerRef{containerRef},
+				optional: isOptional(envFrom.ConfigMapRef.Optional)}
+			resourceCtx.Resource.ConfigMapRefs = append(resourceCtx.Resource.ConfigMapRefs, cfgMapRef)
+		}
+		if envFrom.SecretRef != nil { // same for secrets
+			secretRef := cfgMapRef{Name: envFrom.SecretRef.Name, Containers: []ContainerRef{containerRef},
+				optional: isOptional(envFrom.SecretRef.Optional)}
+			resourceCtx.Resource.SecretRefs = append(resourceCtx.Resource.SecretRefs, secretRef)
+		}
+	}
+	if containerRef.Type == AppContainer || containerRef.Type == SidecarContainer { // other containers do not serve traffic
+		resourceCtx.Resource.ContainerPorts = append(resourceCtx.Resource.ContainerPorts, container.Ports...)
+	}
+	appendNetworkAddresses(resourceCtx, container.Args, argsField, containerRef)
+	appendNetworkAddresses(resourceCtx, container.Command, commandField, containerRef)
+	deferVarExpansion(container, containerRef, resourceCtx)
+}
+
+// isOptional returns true if a reference to a ConfigMap or a Secret is marked "optional"
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// deferVarExpansion stores the container's envs, args and command for a later expansion of "$(VAR)" references,
+// but only if there is any such reference in them
+func deferVarExpansion(container *v1.Container, containerRef ContainerRef, resourceCtx *Resource) {
+	argsAndCmd := []fieldValue{}
+	for idx, val := range container.Args {
+		if hasVarRef(val) {
+			argsAndCmd = append(argsAndCmd, fieldValue{location: indexedLocation(argsField, idx), value: val})
+		}
+	}
+	for idx, val := range container.Command {
+		if hasVarRef(val) {
+			argsAndCmd = append(argsAndCmd, fieldValue{location: indexedLocation(commandField, idx), value: val})
 		}
 	}
+	envHasRefs := slices.ContainsFunc(container.Env, func(e v1.EnvVar) bool { return hasVarRef(e.Value) })
+	if len(argsAndCmd) == 0 && !envHasRefs {
+		return
+	}
+	cEnv := containerEnv{Container: containerRef, EnvFrom: container.EnvFrom, Env: container.Env, ArgsAndCmd: argsAndCmd}
+	resourceCtx.Resource.envsToExpand = append(resourceCtx.Resource.envsToExpand, &cEnv)
+}
+
+// initContainerType distinguishes native sidecars (init containers with restartPolicy Always) from regular init containers
+func initContainerType(container *v1.Container) ContainerType {
+	if container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways {
+		return SidecarContainer
+	}
+	return InitContainer
 }
 
-func appendNetworkAddresses(networkAddresses, values []string) []string {
-	for _, val := range values {
-		if netAddr, ok := networkAddressFromStr(val); ok {
-			networkAddresses = append(networkAddresses, netAddr)
+// containersMountingVolume returns all the containers in the given pod spec that mount the given volume
+func containersMountingVolume(podSpec *v1.PodSpec, volumeName string) []ContainerRef {
+	res := []ContainerRef{}
+	addIfMounting := func(mounts []v1.VolumeMount, containerRef ContainerRef) {
+		for i := range mounts {
+			if mounts[i].Name == volumeName {
+				res = append(res, containerRef)
+				return
+			}
 		}
 	}
-	return networkAddresses
+	for i := range podSpec.Containers {
+		addIfMounting(podSpec.Containers[i].VolumeMounts, ContainerRef{Name: podSpec.Containers[i].Name, Type: AppContainer})
+	}
+	for i := range podSpec.InitContainers {
+		container := &podSpec.InitContainers[i]
+		addIfMounting(container.VolumeMounts, ContainerRef{Name: container.Name, Type: initContainerType(container)})
+	}
+	for i := range podSpec.EphemeralContainers {
+		container := &podSpec.EphemeralContainers[i]
+		addIfMounting(container.VolumeMounts, ContainerRef{Name: container.Name, Type: EphemeralContainer})
+	}
+	return res
+}
+
+func appendNetworkAddresses(resourceCtx *Resource, values []string, field string, containerRef ContainerRef) {
+	for idx, val := range values {
+		if hasVarRef(val) {
+			continue // will be handled after expanding the variables it refers to
+		}
+		for _, netAddr := range networkAddressesFromStr(val) {
+			evidence := AddressEvidence{Container: containerRef, Location: indexedLocation(field, idx), RawValue: val}
+			resourceCtx.addNetworkAddr(netAddr, evidence)
+		}
+	}
+}
+
+// Container fields in which network addresses are looked for, as reported in AddressEvidence.Location
+const (
+	argsField    = "args"
+	commandField = "command"
+)
+
+func envLocation(envName string) string {
+	return "env " + envName
+}
+
+func indexedLocation(field string, idx int) string {
+	return fmt.Sprintf("%s[%d]", field, idx)
+}
+
+// dataKeyLocation returns the location of a key in a ConfigMap or in a Secret.
+// If envName is not empty, the location is of an env var taking its value from the key.
+func dataKeyLocation(kind, name, key, envName string) string {
+	location := fmt.Sprintf("%s %s, key %s", kind, name, key)
+	if envName != "" {
+		location = envLocation(envName) + ", from " + location
+	}
+	return location
 }
 
 // networkAddressFromStr tries to extract a network address from the given string.
@@ -312,6 +677,13 @@ func networkAddressFromStr(value string) (string, bool) {
 }
 
 func networkAddressFromSuffix(value string) (string, bool) {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return value, true
+	}
+	if host, port, err := net.SplitHostPort(value); err == nil && net.ParseIP(host) != nil && isValidPortNum(port) {
+		return value, true // an IP address with a port (which is not a valid URL)
+	}
+
 	host, err := getHostFromURL(value)
 	if err != nil {
 		return "", false // value cannot be interpreted as a URL
@@ -330,7 +702,7 @@ func networkAddressFromSuffix(value string) (string, bool) {
 		}
 	}
 
-	errs := validation.IsDNS1123Subdomain(hostNoPort)
+	errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(hostNoPort, ".")) // allow FQDNs with a trailing dot
 	if len(errs) > 0 {
 		return "", false // host part of the URL is not really a network address
 	}
@@ -351,7 +723,7 @@ func possibleSuffixes(value string) []string {
 
 	var prevRune rune
 	for i, r := range value {
-		if i > 1 && unicode.IsLetter(r) && (prevRune == ':' || prevRune == '=' || prevRune == ' ') {
+		if i > 1 && (unicode.IsLetter(r) || startsWithIPAddress(value[i:])) && (prevRune == ':' || prevRune == '=' || prevRune == ' ') {
 			res = append(res, value[i:])
 		}
 		prevRune = r
@@ -360,6 +732,20 @@ func possibleSuffixes(value string) []string {
 	return res
 }
 
+// startsWithIPAddress returns true if the given string starts with an IP address, followed by a port or a prefix length
+func startsWithIPAddress(value string) bool {
+	ipEnd := strings.IndexAny(value, ":/")
+	if ipEnd < 0 {
+		ipEnd = len(value)
+	}
+	return net.ParseIP(value[:ipEnd]) != nil
+}
+
+func isValidPortNum(port string) bool {
+	portNum, err := strconv.Atoi(port)
+	return err == nil && len(validation.IsValidPortNum(portNum)) == 0
+}
+
 // Attempts to parse the given string as a URL, and extract its Host part.
 // Returns an error if the string cannot be interpreted as a URL
 func getHostFromURL(urlStr string) (string, error) {
@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
 
 	"github.com/np-guard/netpol-analyzer/pkg/manifests/fsscanner"
@@ -28,8 +31,14 @@ func TestNetworkAddressValue(t *testing.T) {
 		"not%a*url":                     {"", false},
 		"123":                           {"", false},
 		"olm-operator-heap-:https://olm-operator-metrics:8443/debug/pprof/heap": {"olm-operator-metrics:8443", true},
-		"-server=my-server:5024":  {"my-server:5024", true},
-		"-server=my-server:502%4": {"", false}, // port number is invalid
+		"-server=my-server:5024":       {"my-server:5024", true},
+		"-server=my-server:502%4":      {"", false},                            // port number is invalid
+		"svc.ns.svc.cluster.local.:80": {"svc.ns.svc.cluster.local.:80", true}, // FQDN with a trailing dot
+		"svc..":                        {"", false},
+		"10.20.0.5:5432":               {"10.20.0.5:5432", true},
+		"--db=10.20.0.5:5432":          {"10.20.0.5:5432", true},
+		"10.20.0.5:99999":              {"", false},
+		"CIDR=192.168.100.0/24":        {"192.168.100.0/24", true},
 	}
 
 	for val, expectedAnswer := range valuesToCheck {
@@ -84,6 +93,32 @@ func TestScanningDeploymentWithConfigMapRef(t *testing.T) {
 	require.Len(t, res.Resource.Labels, 1)
 }
 
+func TestScanningDeploymentWithTopologyAnnotations(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"workload_annotations", "app.yaml"}, 0)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "frontend", res.Resource.Name)
+	require.Empty(t, res.Resource.annotationErrors)
+	// dependencies from both the object and its pod template; DEBUG_URL and HELP_URL are ignored
+	require.Equal(t, []string{"orders.shop:8080", "redis"}, res.Resource.NetworkAddrs)
+	require.Equal(t, []string{"DEBUG_URL", "HELP_URL"}, res.Resource.ignoredEnvs)
+	require.Empty(t, res.Resource.NetworkAddrContainers["redis"])
+
+	resourceInfo, err = loadResourceAsInfo([]string{"workload_annotations", "app.yaml"}, 1)
+	require.Nil(t, err)
+	res, err = k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "batch", res.Resource.Name)
+	require.Equal(t, []string{"redis:6379"}, res.Resource.NetworkAddrs)
+	require.Len(t, res.Resource.annotationErrors, 3) // "orders:http", an empty entry and "1BAD"
+	for _, annotationErr := range res.Resource.annotationErrors {
+		require.Equal(t, resourceInfo.Source, annotationErr.File())
+		malformedErr := &MalformedAnnotationError{}
+		require.True(t, errors.As(annotationErr.Error(), &malformedErr))
+	}
+}
+
 func TestScanningReplicaSet(t *testing.T) {
 	resourceInfo, err := loadResourceAsInfo([]string{"k8s_guestbook", "redis-leader-deployment.yaml"}, 0)
 	require.Nil(t, err)
@@ -103,6 +138,67 @@ func TestScanningConfigMap(t *testing.T) {
 	require.Len(t, res.Data, 5)
 }
 
+func TestScanningSecret(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"secrets", "app.yaml"}, 0)
+	require.Nil(t, err)
+	res, err := k8sSecretFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "shop/db-credentials", res.FullName)
+	require.Equal(t, "orders-db:5432", res.Data["host"]) // data is base64-decoded
+
+	resourceInfo, err = loadResourceAsInfo([]string{"secrets", "app.yaml"}, 1)
+	require.Nil(t, err)
+	res, err = k8sSecretFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "redis://cache:6379", res.Data["CACHE_URL"]) // stringData is taken as is
+}
+
+func TestScanningDeploymentWithSecretRefs(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"secrets", "app.yaml"}, 3)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "api", res.Resource.Name)
+	require.Len(t, res.Resource.SecretKeyRefs, 3)
+	require.True(t, res.Resource.SecretKeyRefs[2].optional)
+	require.Len(t, res.Resource.SecretRefs, 2) // one through envFrom and one as a volume
+	require.Len(t, res.Resource.ConfigMapRefs, 1)
+	require.True(t, res.Resource.ConfigMapRefs[0].optional)
+	require.Empty(t, res.Resource.NetworkAddrs)
+}
+
+func TestScanningStatefulSet(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"statefulsets", "kafka.yaml"}, 3)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "kafka", res.Resource.Name)
+	require.Equal(t, "kafka-headless", res.Resource.podSubdomain)
+	require.True(t, res.hasPodHostname("kafka-0"))
+	require.True(t, res.hasPodHostname("kafka-12"))
+	require.False(t, res.hasPodHostname("kafka-"))
+	require.False(t, res.hasPodHostname("kafka-headless"))
+	require.False(t, res.hasPodHostname("zookeeper-0"))
+
+	resourceInfo, err = loadResourceAsInfo([]string{"statefulsets", "kafka.yaml"}, 2)
+	require.Nil(t, err)
+	svc, err := k8sServiceFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.True(t, svc.Resource.headless)
+}
+
+func TestScanningContainerPorts(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"named_ports", "app.yaml"}, 0)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Len(t, res.Resource.ContainerPorts, 2) // ports of all app containers are collected
+	require.Equal(t, int32(9100), res.declaredPort(&SvcNetworkAttr{Port: 9100, TargetPort: intstr.FromString("metrics")}).ContainerPort)
+	require.Equal(t, int32(8080), res.declaredPort(&SvcNetworkAttr{Port: 8080}).ContainerPort)
+	require.Nil(t, res.declaredPort(&SvcNetworkAttr{Port: 80, TargetPort: intstr.FromString("admin")}))
+	require.Nil(t, res.declaredPort(&SvcNetworkAttr{Port: 8080, Protocol: core.ProtocolUDP}))
+}
+
 func TestScanningIngress(t *testing.T) {
 	resourceInfo, err := loadResourceAsInfo([]string{"bookinfo", "bookinfo-ingress.yaml"}, 0)
 	require.Nil(t, err)
@@ -121,6 +217,121 @@ func TestScanningRoute(t *testing.T) {
 	require.Len(t, toExpose, 1)
 }
 
+func TestScanningHTTPRoute(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"gateway_api", "app.yaml"}, 7)
+	require.Nil(t, err)
+	toExpose := servicesToExpose{}
+	err = gatewayHTTPRouteFromInfo(resourceInfo, toExpose)
+	require.Nil(t, err)
+	require.Len(t, toExpose["store"]["web"], 1)
+	require.Equal(t, portExposer{gateway: "infra/public"}, toExpose["store"]["web"][0].exposer)
+}
+
+func TestScanningTCPRoute(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"gateway_api", "app.yaml"}, 12)
+	require.Nil(t, err)
+	toExpose := servicesToExpose{}
+	err = gatewayTCPRouteFromInfo(resourceInfo, toExpose)
+	require.Nil(t, err)
+	require.Len(t, toExpose["data"]["postgres"], 1)
+	exposed := toExpose["data"]["postgres"][0]
+	require.Equal(t, portExposer{gateway: "infra/public"}, exposed.exposer)
+	require.Equal(t, int32(5432), exposed.port.IntVal)
+	require.Equal(t, tcpRoute, exposed.route.kind)
+	require.Equal(t, "postgres", string(*exposed.parentRef.SectionName))
+}
+
+func TestScanningIstioVirtualService(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"istio_gateway", "app.yaml"}, 11)
+	require.Nil(t, err)
+	toExpose := servicesToExpose{}
+	err = istioVirtualServiceFromInfo(resourceInfo, toExpose)
+	require.Nil(t, err)
+	require.Len(t, toExpose, 1) // the httpbin.org destination is not a service
+	require.Len(t, toExpose["bookinfo"]["details"], 1)
+	exposed := toExpose["bookinfo"]["details"][0]
+	require.Equal(t, portExposer{istioGateway: "istio-system/tls-gateway"}, exposed.exposer)
+	require.Equal(t, int32(9443), exposed.port.IntVal)
+
+	resourceInfo, err = loadResourceAsInfo([]string{"istio_gateway", "app.yaml"}, 10)
+	require.Nil(t, err)
+	err = istioVirtualServiceFromInfo(resourceInfo, toExpose)
+	require.Nil(t, err)
+	require.NotContains(t, toExpose["bookinfo"], "reviews") // only bound to the mesh gateway
+}
+
+func TestIstioServiceFromHost(t *testing.T) {
+	type svcRef struct {
+		namespace string
+		name      string
+		ok        bool
+	}
+
+	hostsToCheck := map[string]svcRef{
+		"reviews":                             {"bookinfo", "reviews", true},
+		"reviews.default.svc.cluster.local":   {"default", "reviews", true},
+		"reviews.default.svc.cluster.local.":  {"default", "reviews", true},
+		"reviews.default.svc.my-cluster.corp": {"default", "reviews", true},
+		"reviews.default":                     {"", "", false}, // Istio does not expand partially qualified names
+		"httpbin.org":                         {"", "", false},
+		"*.bookinfo.com":                      {"", "", false},
+	}
+
+	for host, expected := range hostsToCheck {
+		namespace, name, ok := istioServiceFromHost(host, "bookinfo")
+		require.Equal(t, expected, svcRef{namespace, name, ok}, host)
+	}
+}
+
+func TestScanningServiceMonitor(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"prometheus_monitors", "app.yaml"}, 7)
+	require.Nil(t, err)
+	monitor, err := serviceMonitorFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "frontend", monitor.Name)
+	require.True(t, monitor.Spec.NamespaceSelector.matches(monitor.Namespace, "shop"))
+	require.False(t, monitor.Spec.NamespaceSelector.matches(monitor.Namespace, "monitoring"))
+	require.Len(t, monitor.Spec.Endpoints, 1)
+
+	resourceInfo, err = loadResourceAsInfo([]string{"prometheus_monitors", "app.yaml"}, 2)
+	require.Nil(t, err)
+	svc, err := k8sServiceFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.True(t, selectorMatches(&monitor.Spec.Selector, svc.Resource.labels))
+	require.True(t, monitor.Spec.Endpoints[0].scrapes(&svc.Resource.Network[0]))
+
+	targetPort := intstr.FromString("metrics")
+	require.True(t, (&serviceMonitorEndpoint{TargetPort: &targetPort}).scrapes(&svc.Resource.Network[0]))
+	targetPort = intstr.FromInt32(9100)
+	require.False(t, (&serviceMonitorEndpoint{TargetPort: &targetPort}).scrapes(&svc.Resource.Network[0]))
+}
+
+func TestScanningServiceEndpoints(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"external_services", "app.yaml"}, 3)
+	require.Nil(t, err)
+	svc, err := k8sServiceFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "db", svc.Resource.Name)
+	require.Equal(t, "mydb.rds.amazonaws.com", svc.Resource.ExternalName)
+	require.Empty(t, svc.Resource.Selectors)
+
+	resourceInfo, err = loadResourceAsInfo([]string{"external_services", "app.yaml"}, 5)
+	require.Nil(t, err)
+	slice, err := k8sEndpointSliceFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "legacy-crm", slice.service)
+	require.Equal(t, []string{"10.20.0.15", "10.20.0.16"}, slice.addresses)
+	require.Equal(t, []endpointPort{{name: "http", port: 80}}, slice.ports)
+
+	resourceInfo, err = loadResourceAsInfo([]string{"external_services", "app.yaml"}, 7)
+	require.Nil(t, err)
+	endpoints, err := k8sEndpointsFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "ext-cache", endpoints.service)
+	require.Equal(t, []string{"192.168.10.5"}, endpoints.addresses)
+	require.Equal(t, []endpointPort{{port: 6379}}, endpoints.ports)
+}
+
 func TestScanningCronJob(t *testing.T) {
 	resourceInfo, err := loadResourceAsInfo([]string{"openshift", "openshift-operator-lifecycle-manager-resources.yaml"}, 7)
 	require.Nil(t, err)
@@ -132,6 +343,34 @@ func TestScanningCronJob(t *testing.T) {
 	require.Len(t, res.Resource.Labels, 0)
 }
 
+func TestScanningInitAndSidecarContainers(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"init_containers", "app.yaml"}, 0)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "app", res.Resource.Name)
+	require.Equal(t, "shop/app:1.0.0", res.Resource.Image.ID) // image is only taken from app containers
+	require.Equal(t, []string{"postgres:5432", "fluentd.logging:24224"}, res.Resource.NetworkAddrs)
+	require.Equal(t, []ContainerRef{{"migrate", InitContainer}}, res.Resource.NetworkAddrContainers["postgres:5432"])
+	require.Equal(t, []ContainerRef{{"log-shipper", SidecarContainer}}, res.Resource.NetworkAddrContainers["fluentd.logging:24224"])
+	require.Len(t, res.Resource.ConfigMapRefs, 1)
+	require.Equal(t, []ContainerRef{{"app", AppContainer}}, res.Resource.ConfigMapRefs[0].Containers)
+	require.Len(t, res.Resource.ContainerPorts, 2) // ports of regular init containers are not collected
+	require.NotNil(t, res.declaredPort(&SvcNetworkAttr{Port: 8080}))
+	require.NotNil(t, res.declaredPort(&SvcNetworkAttr{Port: 80, TargetPort: intstr.FromString("http")}))
+	require.Nil(t, res.declaredPort(&SvcNetworkAttr{Port: 80, TargetPort: intstr.FromString("progress")}))
+}
+
+func TestScanningEphemeralContainers(t *testing.T) {
+	resourceInfo, err := loadResourceAsInfo([]string{"init_containers", "app.yaml"}, 2)
+	require.Nil(t, err)
+	res, err := k8sWorkloadObjectFromInfo(resourceInfo)
+	require.Nil(t, err)
+	require.Equal(t, "debugger", res.Resource.Name)
+	require.Equal(t, []string{"nc", "redis:6379"}, res.Resource.NetworkAddrs)
+	require.Equal(t, []ContainerRef{{"debug", EphemeralContainer}}, res.Resource.NetworkAddrContainers["redis:6379"])
+}
+
 func loadResourceAsInfo(resourceDirs []string, infoIndex int) (*resource.Info, error) {
 	currentDir, _ := os.Getwd()
 	resourceRelPath := filepath.Join(resourceDirs...)
@@ -0,0 +1,103 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"strings"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	istioNetworkingGroup = "networking.istio.io"
+	istioMeshGateway     = "mesh" // the reserved gateway name, standing for the sidecars of all the workloads in the mesh
+)
+
+// The types below are a minimal subset of Istio's traffic management API (networking.istio.io/v1)
+
+type istioVirtualService struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              istioVirtualServiceSpec `json:"spec"`
+}
+
+type istioVirtualServiceSpec struct {
+	Gateways []string     `json:"gateways,omitempty"` // no gateways means the "mesh" gateway
+	HTTP     []istioRoute `json:"http,omitempty"`
+	TCP      []istioRoute `json:"tcp,omitempty"`
+	TLS      []istioRoute `json:"tls,omitempty"`
+}
+
+// istioRoute holds the destinations of an http, tcp or tls route
+type istioRoute struct {
+	Route []istioRouteDestination `json:"route,omitempty"`
+}
+
+type istioRouteDestination struct {
+	Destination istioDestination `json:"destination"`
+}
+
+type istioDestination struct {
+	Host string             `json:"host"`
+	Port *istioPortSelector `json:"port,omitempty"` // may be omitted if the destination service has a single port
+}
+
+type istioPortSelector struct {
+	Number int32 `json:"number"`
+}
+
+type istioGateway struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              istioGatewaySpec `json:"spec"`
+}
+
+type istioGatewaySpec struct {
+	Selector map[string]string `json:"selector,omitempty"` // the labels of the gateway pods, in any namespace
+}
+
+func (gw *istioGateway) fullName() string {
+	return gw.Namespace + "/" + gw.Name
+}
+
+// exposingGateways returns the full names ("<namespace>/<name>") of the Istio Gateways the VirtualService is bound to.
+// The "mesh" gateway is ignored, as it only routes traffic between the workloads in the mesh.
+func (vs *istioVirtualService) exposingGateways() []string {
+	gateways := []string{}
+	for _, gatewayRef := range vs.Spec.Gateways {
+		switch {
+		case gatewayRef == istioMeshGateway:
+			continue
+		case strings.Contains(gatewayRef, "/"):
+			gateways = append(gateways, gatewayRef)
+		case strings.Contains(gatewayRef, "."): // the deprecated "<name>.<namespace>.svc.<cluster-domain>" form
+			name, rest, _ := strings.Cut(gatewayRef, ".")
+			namespace, _, _ := strings.Cut(rest, ".")
+			gateways = append(gateways, namespace+"/"+name)
+		default:
+			gateways = append(gateways, vs.Namespace+"/"+gatewayRef)
+		}
+	}
+	return gateways
+}
+
+// istioServiceFromHost returns the namespace and the name of the K8s service a destination host refers to.
+// As in Istio, a short name ("<svc>") refers to a service in the namespace of the VirtualService, and other names
+// must be fully qualified ("<svc>.<ns>.svc.<cluster-domain>"). Other hosts (e.g., of ServiceEntries) are not services.
+func istioServiceFromHost(host, namespace string) (svcNamespace, svcName string, ok bool) {
+	if strings.Contains(host, "*") {
+		return "", "", false
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	switch {
+	case len(labels) == 1:
+		return namespace, labels[0], true
+	case len(labels) > 3 && labels[2] == "svc":
+		return labels[1], labels[0], true
+	}
+	return "", "", false
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"path/filepath"
+
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kresource "sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// kustomizeBuilder is a utility class for building a kustomization in-process (no kubectl) into K8s resources
+type kustomizeBuilder struct {
+	logger       Logger
+	stopOn1stErr bool
+}
+
+// buildKustomization builds the kustomization in the given directory (e.g., an overlay, possibly referring to bases)
+// and returns the resulting resources as Info objects.
+// If the kustomization requests origin annotations (buildMetadata: [originAnnotations]), the Source of each Info
+// is the file the resource originated from. Otherwise, it is the kustomization directory.
+func (kb *kustomizeBuilder) buildKustomization(kustomizationDir string) ([]*resource.Info, []FileProcessingError) {
+	errs := []FileProcessingError{}
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := kustomizer.Run(filesys.MakeFsOnDisk(), kustomizationDir)
+	if err != nil {
+		return nil, appendAndLogNewError(errs, failedBuildingKustomization(kustomizationDir, err), kb.logger)
+	}
+
+	infos := []*resource.Info{}
+	for _, res := range resMap.Resources() {
+		source := resourceSourceInKustomization(res, kustomizationDir)
+		manifest, err := res.AsYAML()
+		if err != nil {
+			errs = appendAndLogNewError(errs, failedReadingFile(source, err), kb.logger)
+			if stopProcessing(kb.stopOn1stErr, errs) {
+				return nil, errs
+			}
+			continue
+		}
+		resInfos, resErrs := infosFromManifest(string(manifest), source, kb.stopOn1stErr)
+		for _, err := range resErrs {
+			errs = appendAndLogNewError(errs, failedReadingFile(source, err), kb.logger)
+			if stopProcessing(kb.stopOn1stErr, errs) {
+				return nil, errs
+			}
+		}
+		infos = append(infos, resInfos...)
+	}
+	return infos, errs
+}
+
+func resourceSourceInKustomization(res *kresource.Resource, kustomizationDir string) string {
+	origin, err := res.GetOrigin()
+	if err != nil || origin == nil || origin.Path == "" || origin.Repo != "" {
+		return kustomizationDir
+	}
+	return filepath.Join(kustomizationDir, origin.Path)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildKustomizationBase(t *testing.T) {
+	kustomizationDir := filepath.Join(getTestsDir(), "kustomize", "base")
+	kb := kustomizeBuilder{logger: NewDefaultLogger()}
+	infos, errs := kb.buildKustomization(kustomizationDir)
+	require.Empty(t, errs)
+	require.Len(t, infos, 4)
+	for _, info := range infos {
+		require.Equal(t, kustomizationDir, info.Source) // no origin annotations in base
+	}
+}
+
+func TestBuildKustomizationOverlay(t *testing.T) {
+	kustomizationDir := filepath.Join(getTestsDir(), "kustomize", "overlays", "prod")
+	kb := kustomizeBuilder{logger: NewDefaultLogger()}
+	infos, errs := kb.buildKustomization(kustomizationDir)
+	require.Empty(t, errs)
+	require.Len(t, infos, 6) // base resources are not double-counted
+
+	resAcc := newResourceAccumulator(NewDefaultLogger(), false)
+	require.Empty(t, resAcc.parseInfos(infos))
+	require.Len(t, resAcc.workloads, 3)
+	for _, wl := range resAcc.workloads {
+		require.Equal(t, "shop-prod", wl.Resource.Namespace)
+		if wl.Resource.Name == "backend" {
+			require.Equal(t, []string{"cache.shop-prod:6379"}, wl.Resource.NetworkAddrs) // from the overlay patch
+			require.Equal(t, filepath.Join(getTestsDir(), "kustomize", "base", "backend.yaml"), wl.Resource.FilePath)
+		}
+	}
+}
+
+func TestBuildKustomizationBadDir(t *testing.T) {
+	kustomizationDir := filepath.Join(getTestsDir(), "k8s_wordpress_example") // no kustomization file
+	kb := kustomizeBuilder{logger: NewDefaultLogger()}
+	infos, errs := kb.buildKustomization(kustomizationDir)
+	require.Empty(t, infos)
+	require.Len(t, errs, 1)
+	require.True(t, errs[0].IsFatal())
+	badKustomization := &FailedBuildingKustomizationError{}
+	require.True(t, errors.As(errs[0].Error(), &badKustomization))
+}
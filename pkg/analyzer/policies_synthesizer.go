@@ -11,16 +11,47 @@ SPDX-License-Identifier: Apache-2.0
 package analyzer
 
 import (
+	"context"
 	"io/fs"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	adminnetpol "sigs.k8s.io/network-policy-api/apis/v1alpha1"
 )
 
 const (
-	DefaultDNSPort = 53 // DefaultDNSPort is the default DNS port to use in the generated policies
+	DefaultDNSPort       = 53              // DefaultDNSPort is the default DNS port to use in the generated policies
+	DefaultClusterDomain = "cluster.local" // DefaultClusterDomain is the default DNS domain of the analyzed cluster
+
+	// DefaultNodeLocalDNSAddress is the link-local address NodeLocal DNSCache listens on by default
+	DefaultNodeLocalDNSAddress = "169.254.20.10"
+)
+
+// PolicyFormat is the kind of policies generated by the PoliciesFromXXX methods
+type PolicyFormat string
+
+const (
+	K8sPolicyFormat    PolicyFormat = "k8s"    // Kubernetes NetworkPolicies (the default)
+	CiliumPolicyFormat PolicyFormat = "cilium" // CiliumNetworkPolicies, which can also refer to external host names
+	CalicoPolicyFormat PolicyFormat = "calico" // Calico NetworkPolicies, with a GlobalNetworkPolicy as the default-deny
+)
+
+// IngressController is a well-known ingress controller (or a shared Gateway API proxy), whose pods carry the traffic
+// to the workloads exposed by Ingress, Route, Gateway API route and Istio VirtualService resources
+type IngressController string
+
+const (
+	IngressNginxController IngressController = "ingress-nginx"        // ingress-nginx, in the "ingress-nginx" namespace
+	OpenShiftRouter        IngressController = "openshift-router"     // the default OpenShift router, in "openshift-ingress"
+	ContourController      IngressController = "contour"              // Contour's Envoy pods, in the "projectcontour" namespace
+	EnvoyGatewayController IngressController = "envoy-gateway"        // Envoy Gateway's proxy pods, in "envoy-gateway-system"
+	IstioIngressGateway    IngressController = "istio-ingressgateway" // Istio's default ingress gateway, in "istio-system"
 )
 
 // WalkFunction is a function for recursively scanning a directory, in the spirit of Go's native filepath.WalkDir()
@@ -36,8 +67,40 @@ type PoliciesSynthesizer struct {
 	stopOnError bool
 	walkFn      WalkFunction
 	dnsPort     intstr.IntOrString
+	dnsPortSet  bool // the DNS port was explicitly set, so DNS presets should not change it
+
+	dnsNamespace     string
+	dnsPodSelector   map[string]string // nil means DNS egress is allowed to any pod in the cluster
+	dnsOverTCP       bool
+	nodeLocalDNSCIDR string
+
+	ingressControllerPeer *networking.NetworkPolicyPeer // nil means exposed workloads may be reached from any pod in the cluster
+	gatewayPeers          bool
+	prometheusPeer        *networking.NetworkPolicyPeer // nil means scraped ports may be reached from any pod in the cluster
+
+	clusterDomain       string
+	allowExternalEgress bool
+	externalConnections bool
+	explain             bool
+	hintsFile           string
+	policyFormat        PolicyFormat
+
+	baselineAdminPolicy  bool
+	crossNsAdminPolicies bool
+	istioAuthzPolicies   bool
+
+	helmValuesFiles []string
+	helmSetValues   []string
+	helmNamespace   string
+
+	namespace     string
+	labelSelector string
 
 	errors []FileProcessingError
+
+	banp          *adminnetpol.BaselineAdminNetworkPolicy
+	anps          []*adminnetpol.AdminNetworkPolicy
+	policyObjects []*unstructured.Unstructured
 }
 
 // PoliciesSynthesizerOption is the type for specifying options for PoliciesSynthesizer,
@@ -72,6 +135,7 @@ func WithStopOnError() PoliciesSynthesizerOption {
 func WithDNSPort(dnsPort int) PoliciesSynthesizerOption {
 	return func(p *PoliciesSynthesizer) {
 		p.dnsPort = intstr.FromInt(dnsPort)
+		p.dnsPortSet = true
 	}
 }
 
@@ -79,6 +143,230 @@ func WithDNSPort(dnsPort int) PoliciesSynthesizerOption {
 func WithDNSNamedPort(dnsPort string) PoliciesSynthesizerOption {
 	return func(p *PoliciesSynthesizer) {
 		p.dnsPort = intstr.FromString(dnsPort)
+		p.dnsPortSet = true
+	}
+}
+
+// WithDNSPods is a functional option to restrict the DNS egress rule in the generated policies to the DNS pods,
+// i.e., the pods in the given namespace which match the given labels. By default, DNS egress is allowed to any pod in the cluster.
+func WithDNSPods(namespace string, podSelector map[string]string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.dnsNamespace = namespace
+		p.dnsPodSelector = podSelector
+	}
+}
+
+// WithKubeDNSPods is a functional option to restrict the DNS egress rule in the generated policies to the kube-dns/CoreDNS pods
+// (the pods labeled "k8s-app: kube-dns" in the "kube-system" namespace)
+func WithKubeDNSPods() PoliciesSynthesizerOption {
+	return WithDNSPods(kubeDNSNamespace, map[string]string{kubeDNSLabelKey: kubeDNSLabelValue})
+}
+
+// WithOpenShiftDNSPods is a functional option to restrict the DNS egress rule in the generated policies to the OpenShift DNS pods
+// (in the "openshift-dns" namespace). As these pods listen on port 5353, the DNS port is also set to 5353, unless it is set
+// using WithDNSPort() or WithDNSNamedPort() (regardless of the order of the options).
+func WithOpenShiftDNSPods() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		WithDNSPods(openShiftDNSNamespace, map[string]string{openShiftDNSLabelKey: openShiftDNSLabelValue})(p)
+		if !p.dnsPortSet {
+			p.dnsPort = intstr.FromInt(openShiftDNSPort)
+		}
+	}
+}
+
+// WithDNSOverTCP is a functional option to allow DNS egress over TCP (in addition to UDP) in the generated policies.
+// DNS clients fall back to TCP for large responses.
+func WithDNSOverTCP() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.dnsOverTCP = true
+	}
+}
+
+// WithNodeLocalDNS is a functional option to also allow DNS egress to the given IP address of NodeLocal DNSCache
+// (usually DefaultNodeLocalDNSAddress). As this cache runs on the host network, it can only be selected by an ipBlock.
+func WithNodeLocalDNS(address string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.nodeLocalDNSCIDR = hostCIDR(address)
+	}
+}
+
+// WithIngressControllerPeer is a functional option to only allow ingress to the workloads exposed by Ingress, Route,
+// Gateway API route and Istio VirtualService resources from the ingress controller pods, i.e., the pods in the given namespace
+// which match the given labels.
+// By default, ingress to these workloads is allowed from any pod in the cluster.
+func WithIngressControllerPeer(namespace string, podSelector map[string]string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		peer := namespacedPodsPeer(namespace, podSelector)
+		p.ingressControllerPeer = &peer
+	}
+}
+
+// WithIngressController is a functional option to set the ingress controller pods (see WithIngressControllerPeer())
+// to the pods of a well-known ingress controller. Unknown ingress controllers are ignored.
+func WithIngressController(controller IngressController) PoliciesSynthesizerOption {
+	controllerPods, ok := ingressControllerPods[controller]
+	if !ok {
+		return func(p *PoliciesSynthesizer) {}
+	}
+	return WithIngressControllerPeer(controllerPods.namespace, controllerPods.podSelector)
+}
+
+// WithGatewayPeers is a functional option to only allow ingress to the workloads exposed by Gateway API routes from
+// the pods of the Gateways the routes are attached to. These are the pods labeled "gateway.networking.k8s.io/gateway-name"
+// with the Gateway's name, in the Gateway's namespace (as deployed by Istio, NGINX Gateway Fabric and other implementations).
+// Similarly, workloads exposed by Istio VirtualServices are only allowed ingress from the pods selected by the Istio Gateways
+// the VirtualServices are bound to (in any namespace).
+// Routes attached to Gateways which are not among the analyzed resources are treated like Ingress resources.
+func WithGatewayPeers() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.gatewayPeers = true
+	}
+}
+
+// WithPrometheusPods is a functional option to only allow ingress to the ports scraped by Prometheus (as specified by
+// ServiceMonitors, PodMonitors and "prometheus.io/scrape" annotations) from the Prometheus pods, i.e., the pods in the given
+// namespace which match the given labels. By default, ingress to these ports is allowed from any pod in the cluster.
+func WithPrometheusPods(namespace string, podSelector map[string]string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		peer := namespacedPodsPeer(namespace, podSelector)
+		p.prometheusPeer = &peer
+	}
+}
+
+// WithKubePrometheusPods is a functional option to set the Prometheus pods (see WithPrometheusPods()) to the pods deployed
+// by kube-prometheus (the pods labeled "app.kubernetes.io/name: prometheus" in the "monitoring" namespace)
+func WithKubePrometheusPods() PoliciesSynthesizerOption {
+	return WithPrometheusPods(kubePrometheusNamespace, map[string]string{prometheusLabelKey: prometheusLabelValue})
+}
+
+// WithOpenShiftMonitoringPods is a functional option to set the Prometheus pods (see WithPrometheusPods()) to the pods of
+// the OpenShift monitoring stack (the pods labeled "app.kubernetes.io/name: prometheus" in the "openshift-monitoring" namespace)
+func WithOpenShiftMonitoringPods() PoliciesSynthesizerOption {
+	return WithPrometheusPods(openShiftMonitoringNamespace, map[string]string{prometheusLabelKey: prometheusLabelValue})
+}
+
+// WithClusterDomain is a functional option to set the DNS domain of the cluster (the default is "cluster.local").
+// It is used when matching fully qualified service names, such as "<svc>.<ns>.svc.<cluster-domain>".
+// An empty domain (or one consisting only of dots) leaves the default domain in place.
+func WithClusterDomain(clusterDomain string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.clusterDomain = strings.Trim(clusterDomain, ".")
+		if p.clusterDomain == "" {
+			p.clusterDomain = DefaultClusterDomain
+		}
+	}
+}
+
+// WithExternalEgress is a functional option which directs PoliciesSynthesizer to allow egress to external host names
+// (e.g., "api.stripe.com:443"), which NetworkPolicies cannot refer to. Egress is then allowed to any address, on the port
+// used for connecting to the external host. By default, such connections are only reported, and are blocked by the policies.
+func WithExternalEgress() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.allowExternalEgress = true
+	}
+}
+
+// WithExternalConnections is a functional option which directs PoliciesSynthesizer to also return "external egress"
+// connections (see ExternalEndpoint) from the ConnectionsFrom*() methods. Such connections have an ExternalTarget instead
+// of a Target. By default, they are only used for synthesizing policies, except for connections through an ExternalName
+// service or a selector-less service (which have a Link), which are always returned.
+func WithExternalConnections() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.externalConnections = true
+	}
+}
+
+// WithExplanations is a functional option which directs PoliciesSynthesizer to set the Evidence of each discovered connection,
+// recording where the network addresses leading to the connection were found (file, container, field and raw value).
+func WithExplanations() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.explain = true
+	}
+}
+
+// WithHints is a functional option to set a YAML hints file, with manual corrections to the discovered connectivity.
+// The file may declare extra connections, suppress discovered connections and expose services.
+// Connections due to hints are marked with FromHint.
+func WithHints(hintsFile string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.hintsFile = hintsFile
+	}
+}
+
+// WithPolicyFormat is a functional option to set the kind of policies to generate (the default is K8sPolicyFormat).
+// With any other format, the PoliciesFromXXX methods return no NetworkPolicies, and the generated policies are available
+// via PolicyObjects(). Admin policies (see WithBaselineAdminNetworkPolicy) are only generated with K8sPolicyFormat;
+// with any other format, a warning is issued if they are requested.
+func WithPolicyFormat(policyFormat PolicyFormat) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.policyFormat = policyFormat
+	}
+}
+
+// WithBaselineAdminNetworkPolicy is a functional option which directs PoliciesSynthesizer to implement the default-deny
+// using a BaselineAdminNetworkPolicy, instead of a default-deny NetworkPolicy in each namespace.
+// The generated policy is available via BaselineAdminNetworkPolicy() after synthesizing the policies.
+func WithBaselineAdminNetworkPolicy() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.baselineAdminPolicy = true
+	}
+}
+
+// WithCrossNamespaceAdminNetworkPolicies is a functional option which directs PoliciesSynthesizer to also generate
+// AdminNetworkPolicies, allowing the discovered connections between workloads in different namespaces.
+// The generated policies are available via AdminNetworkPolicies() after synthesizing the policies.
+func WithCrossNamespaceAdminNetworkPolicies() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.crossNsAdminPolicies = true
+	}
+}
+
+// WithIstioAuthorizationPolicies is a functional option which directs PoliciesSynthesizer to also generate Istio
+// AuthorizationPolicies, allowing each workload to be called only by the service accounts of its discovered sources,
+// on the discovered ports. The generated policies are available via PolicyObjects() after synthesizing the policies.
+func WithIstioAuthorizationPolicies() PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.istioAuthzPolicies = true
+	}
+}
+
+// WithHelmValuesFiles is a functional option to set the values files to use when rendering a Helm chart.
+// Values in later files take precedence over values in earlier files (like helm's --values flag).
+func WithHelmValuesFiles(valuesFiles []string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.helmValuesFiles = valuesFiles
+	}
+}
+
+// WithHelmSetValues is a functional option to override values when rendering a Helm chart.
+// Each value should be in the format of helm's --set flag (e.g., "image.tag=v1,replicas=2").
+func WithHelmSetValues(setValues []string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.helmSetValues = setValues
+	}
+}
+
+// WithHelmReleaseNamespace is a functional option to set the release namespace (.Release.Namespace) when rendering a Helm chart.
+// By default, the "default" namespace is used (like helm's --namespace flag).
+func WithHelmReleaseNamespace(namespace string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.helmNamespace = namespace
+	}
+}
+
+// WithNamespace is a functional option to only analyze resources in the given namespace when scanning a live cluster
+func WithNamespace(namespace string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.namespace = namespace
+	}
+}
+
+// WithLabelSelector is a functional option to only analyze workloads matching the given label selector
+// (e.g., "app=shop,tier!=test") when scanning a live cluster. Other resources, such as the Services and ConfigMaps
+// used by the workloads, are not filtered.
+func WithLabelSelector(labelSelector string) PoliciesSynthesizerOption {
+	return func(p *PoliciesSynthesizer) {
+		p.labelSelector = labelSelector
 	}
 }
 
@@ -91,6 +379,9 @@ func NewPoliciesSynthesizer(options ...PoliciesSynthesizerOption) *PoliciesSynth
 		walkFn:      filepath.WalkDir,
 		dnsPort:     intstr.FromInt(DefaultDNSPort),
 		errors:      []FileProcessingError{},
+
+		clusterDomain: DefaultClusterDomain,
+		policyFormat:  K8sPolicyFormat,
 	}
 	for _, o := range options {
 		o(ps)
@@ -113,13 +404,34 @@ func (ps *PoliciesSynthesizer) ErrorPtrs() []*FileProcessingError {
 	return ret
 }
 
+// BaselineAdminNetworkPolicy returns the BaselineAdminNetworkPolicy generated by the last call to one of the PoliciesFromXXX
+// methods, or nil if the WithBaselineAdminNetworkPolicy option was not given.
+func (ps *PoliciesSynthesizer) BaselineAdminNetworkPolicy() *adminnetpol.BaselineAdminNetworkPolicy {
+	return ps.banp
+}
+
+// AdminNetworkPolicies returns the AdminNetworkPolicies generated by the last call to one of the PoliciesFromXXX methods.
+// These are only generated if the WithCrossNamespaceAdminNetworkPolicies option was given.
+func (ps *PoliciesSynthesizer) AdminNetworkPolicies() []*adminnetpol.AdminNetworkPolicy {
+	return ps.anps
+}
+
+// PolicyObjects returns the policies generated by the last call to one of the PoliciesFromXXX methods,
+// other than NetworkPolicies and admin policies: policies in a policy format other than K8sPolicyFormat
+// (see WithPolicyFormat), followed by Istio AuthorizationPolicies (see WithIstioAuthorizationPolicies).
+func (ps *PoliciesSynthesizer) PolicyObjects() []*unstructured.Unstructured {
+	return ps.policyObjects
+}
+
 // PoliciesFromInfos returns a slice of Kubernetes NetworkPolicies that allow only the connections discovered
 // while processing K8s resources in the given slice of Info objects.
 func (ps *PoliciesSynthesizer) PoliciesFromInfos(infos []*resource.Info) ([]*networking.NetworkPolicy, error) {
 	resources, connections, errs := ps.extractConnectionsFromInfos(infos)
 	policies := []*networking.NetworkPolicy{}
 	if !stopProcessing(ps.stopOnError, errs) {
-		policies = ps.synthNetpols(resources, connections)
+		var synthErrors []FileProcessingError
+		policies, synthErrors = ps.synthNetpols(resources, connections)
+		errs = append(errs, synthErrors...)
 	}
 
 	ps.errors = errs
@@ -142,7 +454,68 @@ func (ps *PoliciesSynthesizer) PoliciesFromFolderPaths(dirPaths []string) ([]*ne
 	resources, connections, errs := ps.extractConnectionsFromFolderPaths(dirPaths)
 	policies := []*networking.NetworkPolicy{}
 	if !stopProcessing(ps.stopOnError, errs) {
-		policies = ps.synthNetpols(resources, connections)
+		var synthErrors []FileProcessingError
+		policies, synthErrors = ps.synthNetpols(resources, connections)
+		errs = append(errs, synthErrors...)
+	}
+
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// PoliciesFromHelmChart returns a slice of Kubernetes NetworkPolicies that allow only the connections discovered
+// while processing the K8s resources rendered from the Helm chart in the given path (a chart directory or archive).
+func (ps *PoliciesSynthesizer) PoliciesFromHelmChart(chartPath string) ([]*networking.NetworkPolicy, error) {
+	resources, connections, errs := ps.extractConnectionsFromHelmChart(chartPath)
+	policies := []*networking.NetworkPolicy{}
+	if !stopProcessing(ps.stopOnError, errs) {
+		var synthErrors []FileProcessingError
+		policies, synthErrors = ps.synthNetpols(resources, connections)
+		errs = append(errs, synthErrors...)
+	}
+
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// PoliciesFromKustomization returns a slice of Kubernetes NetworkPolicies that allow only the connections discovered
+// while processing the K8s resources resulting from building the kustomization in the given directory.
+func (ps *PoliciesSynthesizer) PoliciesFromKustomization(kustomizationDir string) ([]*networking.NetworkPolicy, error) {
+	resources, connections, errs := ps.extractConnectionsFromKustomization(kustomizationDir)
+	policies := []*networking.NetworkPolicy{}
+	if !stopProcessing(ps.stopOnError, errs) {
+		var synthErrors []FileProcessingError
+		policies, synthErrors = ps.synthNetpols(resources, connections)
+		errs = append(errs, synthErrors...)
+	}
+
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// PoliciesFromCluster returns a slice of Kubernetes NetworkPolicies that allow only the connections discovered
+// while processing the K8s resources listed from a live cluster using the given client.
+// clusterName is used to denote the source of the resources (instead of a file path).
+func (ps *PoliciesSynthesizer) PoliciesFromCluster(ctx context.Context, client dynamic.Interface, clusterName string) (
+	[]*networking.NetworkPolicy, error) {
+	resources, connections, errs := ps.extractConnectionsFromCluster(ctx, client, clusterName)
+	policies := []*networking.NetworkPolicy{}
+	if !stopProcessing(ps.stopOnError, errs) {
+		var synthErrors []FileProcessingError
+		policies, synthErrors = ps.synthNetpols(resources, connections)
+		errs = append(errs, synthErrors...)
 	}
 
 	ps.errors = errs
@@ -162,7 +535,7 @@ func (ps *PoliciesSynthesizer) ConnectionsFromInfos(infos []*resource.Info) ([]*
 		return nil, err
 	}
 
-	return connections, nil
+	return ps.returnedConnections(connections), nil
 }
 
 // ConnectionsFromFolderPath returns a slice of Connections, listing the connections discovered
@@ -180,7 +553,54 @@ func (ps *PoliciesSynthesizer) ConnectionsFromFolderPaths(dirPaths []string) ([]
 		return nil, err
 	}
 
-	return connections, nil
+	return ps.returnedConnections(connections), nil
+}
+
+// ConnectionsFromHelmChart returns a slice of Connections, listing the connections discovered
+// while processing the K8s resources rendered from the Helm chart in the given path (a chart directory or archive).
+func (ps *PoliciesSynthesizer) ConnectionsFromHelmChart(chartPath string) ([]*Connections, error) {
+	_, connections, errs := ps.extractConnectionsFromHelmChart(chartPath)
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return ps.returnedConnections(connections), nil
+}
+
+// ConnectionsFromKustomization returns a slice of Connections, listing the connections discovered
+// while processing the K8s resources resulting from building the kustomization in the given directory.
+func (ps *PoliciesSynthesizer) ConnectionsFromKustomization(kustomizationDir string) ([]*Connections, error) {
+	_, connections, errs := ps.extractConnectionsFromKustomization(kustomizationDir)
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return ps.returnedConnections(connections), nil
+}
+
+// ConnectionsFromCluster returns a slice of Connections, listing the connections discovered
+// while processing the K8s resources listed from a live cluster using the given client.
+// clusterName is used to denote the source of the resources (instead of a file path).
+func (ps *PoliciesSynthesizer) ConnectionsFromCluster(ctx context.Context, client dynamic.Interface, clusterName string) (
+	[]*Connections, error) {
+	_, connections, errs := ps.extractConnectionsFromCluster(ctx, client, clusterName)
+	ps.errors = errs
+	if err := hasFatalError(errs); err != nil {
+		return nil, err
+	}
+
+	return ps.returnedConnections(connections), nil
+}
+
+// returnedConnections returns the discovered connections which should be returned to the user,
+// leaving out "external egress" connections which are not through a service, unless requested (see WithExternalConnections())
+func (ps *PoliciesSynthesizer) returnedConnections(connections []*Connections) []*Connections {
+	if ps.externalConnections {
+		return connections
+	}
+	return slices.DeleteFunc(connections, func(conn *Connections) bool { return conn.ExternalTarget != nil && conn.Link == nil })
 }
 
 func (ps *PoliciesSynthesizer) extractConnectionsFromInfos(infos []*resource.Info) (
@@ -220,6 +640,48 @@ func (ps *PoliciesSynthesizer) extractConnectionsFromFolderPaths(dirPaths []stri
 	return wls, conns, fileErrors
 }
 
+// Renders the given Helm chart (offline) and extracts required connections between the rendered workloads
+func (ps *PoliciesSynthesizer) extractConnectionsFromHelmChart(chartPath string) (
+	[]*Resource, []*Connections, []FileProcessingError) {
+	hr := helmRenderer{ps.logger, ps.stopOnError, ps.helmValuesFiles, ps.helmSetValues, ps.helmNamespace}
+	infos, renderErrors := hr.renderChart(chartPath)
+	if stopProcessing(ps.stopOnError, renderErrors) {
+		return nil, nil, renderErrors
+	}
+
+	wls, conns, errs := ps.extractConnectionsFromInfos(infos)
+	renderErrors = append(renderErrors, errs...)
+	return wls, conns, renderErrors
+}
+
+// Builds the kustomization in the given directory (in-process) and extracts required connections between the resulting workloads
+func (ps *PoliciesSynthesizer) extractConnectionsFromKustomization(kustomizationDir string) (
+	[]*Resource, []*Connections, []FileProcessingError) {
+	kb := kustomizeBuilder{ps.logger, ps.stopOnError}
+	infos, buildErrors := kb.buildKustomization(kustomizationDir)
+	if stopProcessing(ps.stopOnError, buildErrors) {
+		return nil, nil, buildErrors
+	}
+
+	wls, conns, errs := ps.extractConnectionsFromInfos(infos)
+	buildErrors = append(buildErrors, errs...)
+	return wls, conns, buildErrors
+}
+
+// Lists the relevant resources from a live cluster and extracts required connections between its workloads
+func (ps *PoliciesSynthesizer) extractConnectionsFromCluster(ctx context.Context, client dynamic.Interface, clusterName string) (
+	[]*Resource, []*Connections, []FileProcessingError) {
+	cs := clusterScanner{ps.logger, ps.stopOnError, client, clusterName, ps.namespace, ps.labelSelector}
+	infos, listErrors := cs.listInfos(ctx)
+	if stopProcessing(ps.stopOnError, listErrors) {
+		return nil, nil, listErrors
+	}
+
+	wls, conns, errs := ps.extractConnectionsFromInfos(infos)
+	listErrors = append(listErrors, errs...)
+	return wls, conns, listErrors
+}
+
 func (ps *PoliciesSynthesizer) extractConnections(resAcc *resourceAccumulator) (
 	[]*Resource, []*Connections, []FileProcessingError) {
 	if len(resAcc.workloads) == 0 {
@@ -232,10 +694,31 @@ func (ps *PoliciesSynthesizer) extractConnections(resAcc *resourceAccumulator) (
 		return nil, nil, fileErrors
 	}
 
-	resAcc.exposeServices()
-
-	// Discover all connections between resources
-	connections := discoverConnections(resAcc.workloads, resAcc.services, ps.logger)
+	attachServiceEndpoints(resAcc.services, resAcc.endpoints)
+	fileErrors = append(fileErrors, resAcc.checkServiceTargetPorts()...)
+	fileErrors = append(fileErrors, resAcc.exposeServices()...)
+	fileErrors = append(fileErrors, exposeMonitoredServices(resAcc.serviceMonitors, resAcc.services, ps.logger)...)
+	fileErrors = append(fileErrors, exposeMonitoredPods(resAcc.podMonitors, resAcc.workloads, ps.logger)...)
+
+	hints := &connectivityHints{}
+	if ps.hintsFile != "" {
+		var err error
+		hints, err = loadHints(ps.hintsFile)
+		if err != nil {
+			return nil, nil, appendAndLogNewError(fileErrors, failedLoadingHints(ps.hintsFile, err), ps.logger)
+		}
+	}
+	fileErrors = append(fileErrors, hints.exposeServices(resAcc.services, ps.logger)...)
+
+	// Discover all connections between resources, and then correct them using the hints
+	connections := discoverConnections(resAcc.workloads, resAcc.services, ps.clusterDomain, ps.logger)
+	connections, hintErrors := hints.applyToConnections(connections, resAcc.workloads, resAcc.services, ps.logger)
+	fileErrors = append(fileErrors, hintErrors...)
+	if !ps.explain {
+		for _, conn := range connections {
+			conn.Evidence = nil
+		}
+	}
 	return resAcc.workloads, connections, fileErrors
 }
 
@@ -8,6 +8,7 @@ package analyzer
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,7 +19,10 @@ import (
 
 	"github.com/stretchr/testify/require"
 	core "k8s.io/api/core/v1"
+	network "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
 
 	"github.com/np-guard/netpol-analyzer/pkg/manifests/fsscanner"
@@ -111,6 +115,534 @@ func TestPoliciesSynthesizerAPIMultiplePaths(t *testing.T) {
 	require.Len(t, conns, 1)
 }
 
+func TestPoliciesSynthesizerAPIHelmChart(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "helm_chart", "shop")
+	valuesFile := filepath.Join(getTestsDir(), "helm_chart", "values-prod.yaml")
+	synthesizer := NewPoliciesSynthesizer(WithHelmValuesFiles([]string{valuesFile}), WithHelmSetValues([]string{"frontend.port=8081"}))
+	netpols, err := synthesizer.PoliciesFromHelmChart(chartPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, netpols, 4) // frontend, backend, cache and namespace default deny
+
+	conns, err := synthesizer.ConnectionsFromHelmChart(chartPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, conns, 4) // internet->frontend, frontend->backend, frontend->cache, backend->cache
+	for _, conn := range conns {
+		require.Contains(t, conn.Target.Resource.FilePath, filepath.Join(chartPath, "templates"))
+	}
+}
+
+func TestPoliciesSynthesizerAPIHelmChartBadChart(t *testing.T) {
+	chartPath := filepath.Join(getTestsDir(), "k8s_wordpress_example")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromHelmChart(chartPath)
+	require.NotNil(t, err)
+	badChart := &FailedRenderingChartError{}
+	require.True(t, errors.As(err, &badChart))
+	require.Empty(t, netpols)
+}
+
+func TestPoliciesSynthesizerAPIKustomization(t *testing.T) {
+	kustomizationDir := filepath.Join(getTestsDir(), "kustomize", "overlays", "prod")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromKustomization(kustomizationDir)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, netpols, 4) // frontend, backend, cache and namespace default deny
+
+	conns, err := synthesizer.ConnectionsFromKustomization(kustomizationDir)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, conns, 3) // internet->frontend, frontend->backend, backend->cache
+
+	_, err = synthesizer.ConnectionsFromKustomization(filepath.Join(getTestsDir(), "kustomize"))
+	badKustomization := &FailedBuildingKustomizationError{}
+	require.True(t, errors.As(err, &badKustomization))
+}
+
+func TestPoliciesSynthesizerAPICluster(t *testing.T) {
+	client := newFakeClusterClient(t, "k8s_wordpress_example", "default")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromCluster(context.Background(), client, testClusterName)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, netpols, 3) // wordpress, mysql and namespace default deny
+
+	conns, err := synthesizer.ConnectionsFromCluster(context.Background(), client, testClusterName)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, conns, 2) // internet->wordpress and wordpress->mysql
+	require.Equal(t, testClusterName+"/default", conns[0].Target.Resource.FilePath)
+
+	synthesizer = NewPoliciesSynthesizer(WithNamespace("other"))
+	_, err = synthesizer.ConnectionsFromCluster(context.Background(), client, testClusterName)
+	noK8sRes := &NoK8sResourcesFoundError{}
+	require.True(t, errors.As(err, &noK8sRes))
+}
+
+func TestPoliciesSynthesizerAPIInitContainers(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "init_containers")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	require.Len(t, conns, 4)
+	for _, conn := range conns {
+		require.Len(t, conn.SourceContainers, 1)
+		container := conn.SourceContainers[0]
+		switch conn.Target.Resource.Name {
+		case "postgres":
+			require.Equal(t, ContainerRef{"migrate", InitContainer}, container)
+			require.True(t, conn.StartupOnly)
+		case "fluentd":
+			require.Equal(t, ContainerRef{"log-shipper", SidecarContainer}, container)
+			require.False(t, conn.StartupOnly)
+		case "redis":
+			require.False(t, conn.StartupOnly)
+		}
+	}
+
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Len(t, netpols, 7) // 5 workloads and 2 namespaces
+}
+
+func TestPoliciesSynthesizerAPISecrets(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "secrets")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	targets := []string{}
+	for _, conn := range conns {
+		if conn.Source != nil && conn.Source.Resource.Name == "api" {
+			targets = append(targets, conn.Target.Resource.Name)
+			require.Equal(t, []ContainerRef{{"api", AppContainer}}, conn.SourceContainers)
+		}
+	}
+	require.ElementsMatch(t, []string{"orders-db", "cache", "queue"}, targets)
+
+	// values taken from Secrets must never make it to the output
+	out, err := json.Marshal(conns)
+	require.Nil(t, err)
+	require.NotContains(t, string(out), "orders-db:5432")
+	require.NotContains(t, string(out), "redis://cache:6379")
+	require.NotContains(t, string(out), "s3cr3t")
+}
+
+func TestPoliciesSynthesizerAPIVarExpansion(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "env_interpolation")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	targets := []string{}
+	for _, conn := range conns {
+		if conn.Source != nil && conn.Source.Resource.Name == "frontend" {
+			targets = append(targets, conn.Target.Resource.Name)
+			// the address composed using a Secret value is used, but is not part of the output
+			require.Contains(t, conn.Source.Resource.NetworkAddrs, "orders-svc:8080")
+			require.Contains(t, conn.Source.Resource.NetworkAddrs, "shop-cache:6379")
+			require.NotContains(t, conn.Source.Resource.NetworkAddrs, "shop-db:5432")
+		}
+	}
+	require.ElementsMatch(t, []string{"orders", "cache", "db"}, targets)
+}
+
+func TestPoliciesSynthesizerAPIStatefulSets(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "statefulsets")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	connsStr := []string{}
+	for _, conn := range conns {
+		if conn.Source != nil {
+			connStr := fmt.Sprintf("%s->%s", conn.Source.Resource.Name, conn.Target.Resource.Name)
+			connsStr = append(connsStr, connStr)
+			require.Len(t, conn.Source.Resource.UsedPorts, 1)
+		}
+	}
+	// zookeeper->zookeeper is a self-loop
+	require.ElementsMatch(t, []string{"kafka->zookeeper", "producer->kafka", "consumer->kafka"}, connsStr)
+}
+
+func TestPoliciesSynthesizerAPIClusterDomain(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cluster_domain")
+	tests := []struct {
+		clusterDomain   string
+		withOption      bool
+		expectedTargets []string
+	}{
+		{"", false, []string{"api", "cache"}}, // default cluster domain
+		{"", true, []string{"api", "cache"}},  // falls back to the default cluster domain
+		{"..", true, []string{"api", "cache"}},
+		{"corp.internal", true, []string{"api", "db"}},
+		{".corp.internal.", true, []string{"api", "db"}},
+	}
+	for _, tt := range tests {
+		options := []PoliciesSynthesizerOption{}
+		if tt.withOption {
+			options = append(options, WithClusterDomain(tt.clusterDomain))
+		}
+		synthesizer := NewPoliciesSynthesizer(options...)
+		conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+		require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+		require.Empty(t, synthesizer.Errors())
+		targets := []string{}
+		for _, conn := range conns {
+			if conn.Source != nil {
+				targets = append(targets, conn.Target.Resource.Name)
+			}
+		}
+		require.ElementsMatch(t, tt.expectedTargets, targets, tt.clusterDomain)
+	}
+}
+
+func TestPoliciesSynthesizerAPIExternalEgress(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "external_egress")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	for _, conn := range conns {
+		require.NotNil(t, conn.Target) // external egress connections are only returned if requested
+	}
+
+	synthesizer = NewPoliciesSynthesizer(WithExternalConnections())
+	conns, err = synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+	externalTargets := []ExternalEndpoint{}
+	for _, conn := range conns {
+		if conn.ExternalTarget != nil {
+			require.Nil(t, conn.Target)
+			require.Equal(t, "payments", conn.Source.Resource.Name)
+			externalTargets = append(externalTargets, *conn.ExternalTarget)
+		}
+	}
+	expectedTargets := []ExternalEndpoint{
+		{IPBlock: "10.20.0.5/32", Port: 5432},
+		{IPBlock: "2001:db8::20/128", Port: 5432},
+		{IPBlock: "192.168.100.0/24"}, // but not TRUSTED_PROXIES and ALLOWED_CLIENTS
+		{Host: "api.stripe.com", Port: 443},
+	}
+	require.ElementsMatch(t, expectedTargets, externalTargets)
+
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	paymentsEgress := egressOfNetpol(t, netpols, "payments-netpol")
+	require.Len(t, paymentsEgress, 5) // orders, 3 ipBlocks and DNS
+	require.Equal(t, "10.20.0.5/32", paymentsEgress[1].To[0].IPBlock.CIDR)
+	require.Equal(t, int32(5432), paymentsEgress[1].Ports[0].Port.IntVal)
+	require.Equal(t, "2001:db8::20/128", paymentsEgress[2].To[0].IPBlock.CIDR)
+	require.Equal(t, int32(5432), paymentsEgress[2].Ports[0].Port.IntVal)
+	require.Equal(t, "192.168.100.0/24", paymentsEgress[3].To[0].IPBlock.CIDR)
+	require.Empty(t, paymentsEgress[3].Ports)
+
+	synthesizer = NewPoliciesSynthesizer(WithExternalEgress())
+	netpols, err = synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	paymentsEgress = egressOfNetpol(t, netpols, "payments-netpol")
+	require.Len(t, paymentsEgress, 6) // also egress to any address on port 443
+	require.Len(t, paymentsEgress[4].To, 2)
+	require.Equal(t, "0.0.0.0/0", paymentsEgress[4].To[0].IPBlock.CIDR)
+	require.Equal(t, int32(443), paymentsEgress[4].Ports[0].Port.IntVal)
+}
+
+func TestIsPeerListCIDR(t *testing.T) {
+	require.True(t, isPeerListCIDR("10.0.0.0/8", []AddressEvidence{{Location: "env NO_PROXY"}}))
+	require.True(t, isPeerListCIDR("10.0.0.0/8", []AddressEvidence{{Location: "args[1]", RawValue: "--allowed-cidrs=10.0.0.0/8"}}))
+	require.True(t, isPeerListCIDR("10.0.0.0/8", []AddressEvidence{{Location: "ConfigMap app, key TRUSTED_NETWORKS"}}))
+	require.False(t, isPeerListCIDR("10.0.0.0/8", []AddressEvidence{{Location: "env NO_PROXY"}, {Location: "env PARTNER_NET"}}))
+	require.False(t, isPeerListCIDR("10.0.0.0/8", []AddressEvidence{{Location: "args[0]", RawValue: "10.0.0.0/8"}}))
+	require.False(t, isPeerListCIDR("10.0.0.5", []AddressEvidence{{Location: "env TRUSTED_HOST"}})) // not a CIDR
+}
+
+func egressOfNetpol(t *testing.T, netpols []*network.NetworkPolicy, netpolName string) []network.NetworkPolicyEgressRule {
+	for _, netpol := range netpols {
+		if netpol.Name == netpolName {
+			return netpol.Spec.Egress
+		}
+	}
+	require.Failf(t, "missing NetworkPolicy", "no NetworkPolicy named %s", netpolName)
+	return nil
+}
+
+func ingressOfNetpol(t *testing.T, netpols []*network.NetworkPolicy, netpolName string) []network.NetworkPolicyIngressRule {
+	for _, netpol := range netpols {
+		if netpol.Name == netpolName {
+			return netpol.Spec.Ingress
+		}
+	}
+	require.Failf(t, "missing NetworkPolicy", "no NetworkPolicy named %s", netpolName)
+	return nil
+}
+
+func TestPoliciesSynthesizerAPINamedPorts(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "named_ports")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	errs := synthesizer.Errors()
+	require.Len(t, errs, 1)
+	undeclaredPort := &UndeclaredTargetPortError{}
+	require.True(t, errors.As(errs[0].Error(), &undeclaredPort))
+	require.Equal(t, "service web-admin targets port admin, which is not declared by any container of web", undeclaredPort.Error())
+	require.False(t, errs[0].IsSevere())
+	require.Equal(t, filepath.Join(dirPath, "app.yaml"), errs[0].File())
+
+	clientEgress := egressOfNetpol(t, netpols, "client-netpol")
+	require.Len(t, clientEgress, 2) // web and DNS
+	require.Len(t, clientEgress[0].Ports, 2)
+	for _, port := range clientEgress[0].Ports {
+		require.Equal(t, intstr.Int, port.Port.Type) // named ports are resolved to the declared port numbers
+		require.Contains(t, []int32{8080, 9100}, port.Port.IntVal)
+	}
+}
+
+func TestPoliciesSynthesizerAPIExplanations(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "init_containers")
+	conns, err := NewPoliciesSynthesizer().ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	for _, conn := range conns {
+		require.Nil(t, conn.Evidence) // only set when explanations are requested
+	}
+
+	conns, err = NewPoliciesSynthesizer(WithExplanations()).ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	for _, conn := range conns {
+		if conn.Source == nil || conn.Source.Resource.Name != "app" || conn.Target.Resource.Name != "redis" {
+			continue
+		}
+		require.Len(t, conn.Evidence, 1)
+		evidence := conn.Evidence[0]
+		require.Equal(t, filepath.Join(dirPath, "app.yaml"), evidence.FilePath)
+		require.Equal(t, ContainerRef{"app", AppContainer}, evidence.Container)
+		require.Equal(t, "ConfigMap app-config, key CACHE_ADDR", evidence.Location)
+		require.Equal(t, "redis:6379", evidence.RawValue)
+		require.Equal(t, "redis:6379", evidence.MatchedAddress)
+	}
+}
+
+func TestPoliciesSynthesizerAPIExplanationsOfSecrets(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "secrets")
+	conns, err := NewPoliciesSynthesizer(WithExplanations()).ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	found := false
+	for _, conn := range conns {
+		if conn.Source == nil || conn.Source.Resource.Name != "api" || conn.Target.Resource.Name != "orders-db" {
+			continue
+		}
+		found = true
+		require.NotEmpty(t, conn.Evidence)
+		for _, evidence := range conn.Evidence {
+			require.Contains(t, evidence.Location, "Secret")
+			require.Empty(t, evidence.RawValue)
+			require.Empty(t, evidence.MatchedAddress)
+		}
+	}
+	require.True(t, found)
+
+	// values taken from Secrets must never make it to the output, even as evidence
+	out, err := json.Marshal(conns)
+	require.Nil(t, err)
+	require.NotContains(t, string(out), "orders-db:5432")
+}
+
+func TestPoliciesSynthesizerAPISameNamedWorkloads(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "same_named_workloads")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	netpolNames := []string{}
+	for _, netpol := range netpols {
+		netpolNames = append(netpolNames, netpol.Namespace+"/"+netpol.Name)
+	}
+	expectedNames := []string{
+		"team-a/backend-deployment-2-netpol", // the Deployment "backend-deployment" takes "backend-deployment-netpol"
+		"team-a/backend-statefulset-netpol",
+		"team-a/backend-deployment-netpol",
+		"team-a/frontend-netpol",
+		"team-b/backend-netpol",
+		"team-b/frontend-netpol",
+		"team-a/default-deny-in-namespace-team-a",
+		"team-b/default-deny-in-namespace-team-b",
+	}
+	require.Equal(t, expectedNames, netpolNames)
+
+	for _, netpol := range netpols {
+		switch netpol.Name {
+		case "backend-deployment-netpol":
+			require.Equal(t, map[string]string{"app": "backend-deployment"}, netpol.Spec.PodSelector.MatchLabels)
+		case "backend-deployment-2-netpol":
+			require.Equal(t, map[string]string{"app": "backend"}, netpol.Spec.PodSelector.MatchLabels)
+		case "frontend-netpol": // each frontend may only connect to the backend in its own namespace
+			require.Len(t, netpol.Spec.Egress, 2) // backend and DNS
+			require.Nil(t, netpol.Spec.Egress[0].To[0].NamespaceSelector)
+			require.Equal(t, map[string]string{"app": "backend"}, netpol.Spec.Egress[0].To[0].PodSelector.MatchLabels)
+		}
+	}
+}
+
+func TestPoliciesSynthesizerAPIAdminPolicies(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer(WithBaselineAdminNetworkPolicy(), WithCrossNamespaceAdminNetworkPolicies())
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Len(t, netpols, 3) // no default-deny NetworkPolicies
+
+	banp := synthesizer.BaselineAdminNetworkPolicy()
+	require.NotNil(t, banp)
+	require.Equal(t, "default", banp.Name)
+	require.Equal(t, []string{"orders", "shop"}, banp.Spec.Subject.Namespaces.MatchExpressions[0].Values)
+
+	anps := synthesizer.AdminNetworkPolicies()
+	require.Len(t, anps, 2) // frontend -> orders is the only cross-namespace connection
+	require.Equal(t, "orders.orders-cross-namespace", anps[0].Name)
+	require.Len(t, anps[0].Spec.Ingress, 1)
+	require.Empty(t, anps[0].Spec.Egress)
+	require.Equal(t, map[string]string{"app": "frontend"}, anps[0].Spec.Ingress[0].From[0].Pods.PodSelector.MatchLabels)
+	require.Equal(t, int32(8080), (*anps[0].Spec.Ingress[0].Ports)[0].PortNumber.Port) // named target port is resolved
+	require.Equal(t, "shop.frontend-cross-namespace", anps[1].Name)
+	require.Empty(t, anps[1].Spec.Ingress) // exposure by a LoadBalancer service is left to the NetworkPolicy
+	require.Len(t, anps[1].Spec.Egress, 1)
+}
+
+func TestPoliciesSynthesizerAPIAdminPolicyNames(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "admin_policy_names")
+	synthesizer := NewPoliciesSynthesizer(WithCrossNamespaceAdminNetworkPolicies())
+	_, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	anpNames := []string{}
+	for _, anp := range synthesizer.AdminNetworkPolicies() {
+		anpNames = append(anpNames, anp.Name)
+	}
+	require.ElementsMatch(t, []string{"a.b-c-cross-namespace", "a-b.c-cross-namespace", "orders.orders-cross-namespace"}, anpNames)
+
+	peers := []network.NetworkPolicyPeer{
+		{NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "shop"}}},
+		{NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "billing"}}},
+		{NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "shop"}}},
+	}
+	require.Equal(t, "allow-from-billing,shop", adminPolicyRuleName("allow-from-", peers)) // named after all the peer namespaces
+	peers[0].NamespaceSelector.MatchLabels[namespaceNameLabel] = strings.Repeat("n", adminPolicyRuleNameMaxLength)
+	require.Equal(t, "allow-from-3-namespaces", adminPolicyRuleName("allow-from-", peers))
+}
+
+func TestPoliciesSynthesizerAPINoAdminPolicies(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Len(t, netpols, 5) // including default-deny NetworkPolicies for both namespaces
+	require.Nil(t, synthesizer.BaselineAdminNetworkPolicy())
+	require.Empty(t, synthesizer.AdminNetworkPolicies())
+}
+
+func TestPoliciesSynthesizerAPICiliumPolicies(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "external_egress")
+	synthesizer := NewPoliciesSynthesizer(WithPolicyFormat(CiliumPolicyFormat))
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, netpols)
+
+	policies := synthesizer.PolicyObjects()
+	require.Len(t, policies, 3) // orders, payments and the default-deny policy
+	for _, policy := range policies {
+		require.Equal(t, "CiliumNetworkPolicy", policy.GetKind())
+	}
+	payments := policies[1]
+	require.Equal(t, "payments-netpol", payments.GetName())
+	egress, found, err := unstructured.NestedSlice(payments.Object, "spec", "egress")
+	require.True(t, found)
+	require.Nil(t, err)
+	require.Contains(t, egress, map[string]interface{}{ // egress to an external host is allowed by its name
+		"toFQDNs": []interface{}{map[string]interface{}{"matchName": "api.stripe.com"}},
+		"toPorts": []interface{}{map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": "443", "protocol": "TCP"}},
+		}},
+	})
+}
+
+func TestPoliciesSynthesizerAPICalicoPolicies(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer(WithPolicyFormat(CalicoPolicyFormat), WithBaselineAdminNetworkPolicy())
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, netpols)
+	require.Nil(t, synthesizer.BaselineAdminNetworkPolicy()) // admin policies are only generated in the k8s format
+	errs := synthesizer.Errors()
+	require.Len(t, errs, 1)
+	adminPoliciesErr := &AdminPoliciesNotSupportedError{}
+	require.True(t, errors.As(errs[0].Error(), &adminPoliciesErr))
+	require.Equal(t, "admin policies are not generated with the calico policy format", adminPoliciesErr.Error())
+	require.False(t, errs[0].IsSevere())
+
+	policies := synthesizer.PolicyObjects()
+	require.Len(t, policies, 4)
+	defaultDeny := policies[3]
+	require.Equal(t, "GlobalNetworkPolicy", defaultDeny.GetKind())
+	nsSelector, _, _ := unstructured.NestedString(defaultDeny.Object, "spec", "namespaceSelector")
+	require.Equal(t, "kubernetes.io/metadata.name in {'orders', 'shop'}", nsSelector)
+	ordersSelector, _, _ := unstructured.NestedString(policies[0].Object, "spec", "selector")
+	require.Equal(t, "app == 'orders'", ordersSelector)
+}
+
+func TestPoliciesSynthesizerAPIIstioAuthorizationPolicies(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer(WithIstioAuthorizationPolicies())
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.NotEmpty(t, netpols) // AuthorizationPolicies are generated in addition to the NetworkPolicies
+
+	policies := synthesizer.PolicyObjects()
+	require.Len(t, policies, 5)
+	for _, policy := range policies {
+		require.Equal(t, "AuthorizationPolicy", policy.GetKind())
+	}
+	require.Equal(t, "orders-authz", policies[0].GetName())
+	rules, _, _ := unstructured.NestedSlice(policies[0].Object, "spec", "rules")
+	require.Len(t, rules, 1)
+	from, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "from")
+	principals, _, _ := unstructured.NestedStringSlice(from[0].(map[string]interface{}), "source", "principals")
+	require.Equal(t, []string{"cluster.local/ns/shop/sa/frontend"}, principals)
+
+	frontendRules, _, _ := unstructured.NestedSlice(policies[2].Object, "spec", "rules")
+	require.Len(t, frontendRules, 1)
+	require.NotContains(t, frontendRules[0], "from") // frontend is exposed by a LoadBalancer Service
+
+	require.Equal(t, "allow-nothing", policies[4].GetName())
+	require.Equal(t, "shop", policies[4].GetNamespace())
+}
+
+func TestPoliciesSynthesizerAPIIstioNamedPorts(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "istio_named_ports")
+	synthesizer := NewPoliciesSynthesizer(WithIstioAuthorizationPolicies())
+	_, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Len(t, synthesizer.Errors(), 1) // web-admin targets the undeclared admin port
+
+	policies := synthesizer.PolicyObjects()
+	require.Equal(t, "web-authz", policies[0].GetName())
+	webRules, _, _ := unstructured.NestedSlice(policies[0].Object, "spec", "rules")
+	require.Len(t, webRules, 2)
+	to, _, _ := unstructured.NestedSlice(webRules[0].(map[string]interface{}), "to")
+	ports, _, _ := unstructured.NestedStringSlice(to[0].(map[string]interface{}), "operation", "ports")
+	require.Equal(t, []string{"8080"}, ports) // the http named port is resolved to the declared port number
+	require.NotContains(t, webRules[1], "to") // AuthorizationPolicies cannot refer to named ports, so any port is allowed
+}
+
+func TestCalicoSelector(t *testing.T) {
+	require.Equal(t, "all()", calicoSelector(nil))
+	require.Equal(t, "app == 'shop' && tier == 'web'", calicoSelector(map[string]string{"tier": "web", "app": "shop"}))
+}
+
 func TestPoliciesSynthesizerAPIDnsPort(t *testing.T) {
 	dirPath := filepath.Join(getTestsDir(), "acs-security-demos")
 	synthesizer := NewPoliciesSynthesizer(WithDNSPort(5353))
@@ -131,6 +663,245 @@ func TestPoliciesSynthesizerAPIDnsPort(t *testing.T) {
 	}
 }
 
+func TestPoliciesSynthesizerAPIDNSPods(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer(WithOpenShiftDNSPods(), WithDNSPort(5353), WithDNSOverTCP(), WithNodeLocalDNS("fd00::10"))
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	frontendNetpol := netpols[2]
+	require.Equal(t, "frontend-netpol", frontendNetpol.Name)
+	dnsRule := frontendNetpol.Spec.Egress[len(frontendNetpol.Spec.Egress)-1]
+	require.Len(t, dnsRule.Ports, 2)
+	require.Equal(t, core.ProtocolUDP, *dnsRule.Ports[0].Protocol)
+	require.Equal(t, core.ProtocolTCP, *dnsRule.Ports[1].Protocol)
+	require.Equal(t, int32(5353), dnsRule.Ports[1].Port.IntVal)
+	require.Len(t, dnsRule.To, 2)
+	require.Equal(t, "openshift-dns", dnsRule.To[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+	require.Equal(t, map[string]string{"dns.operator.openshift.io/daemonset-dns": "default"}, dnsRule.To[0].PodSelector.MatchLabels)
+	require.Equal(t, "fd00::10/128", dnsRule.To[1].IPBlock.CIDR)
+}
+
+func TestPoliciesSynthesizerAPIOpenShiftDNSPort(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	cases := []struct {
+		name         string
+		options      []PoliciesSynthesizerOption
+		expectedPort intstr.IntOrString
+	}{
+		{"preset", []PoliciesSynthesizerOption{WithOpenShiftDNSPods()}, intstr.FromInt(5353)},
+		{"portBeforePreset", []PoliciesSynthesizerOption{WithDNSPort(DefaultDNSPort), WithOpenShiftDNSPods()}, intstr.FromInt(53)},
+		{"portAfterPreset", []PoliciesSynthesizerOption{WithOpenShiftDNSPods(), WithDNSPort(5300)}, intstr.FromInt(5300)},
+		{"namedPort", []PoliciesSynthesizerOption{WithDNSNamedPort("dns"), WithOpenShiftDNSPods()}, intstr.FromString("dns")},
+		{"otherPods", []PoliciesSynthesizerOption{WithKubeDNSPods()}, intstr.FromInt(53)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			netpols, err := NewPoliciesSynthesizer(tc.options...).PoliciesFromFolderPath(dirPath)
+			require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+			frontendEgress := egressOfNetpol(t, netpols, "frontend-netpol")
+			require.Equal(t, tc.expectedPort, *frontendEgress[len(frontendEgress)-1].Ports[0].Port)
+		})
+	}
+}
+
+func TestPoliciesSynthesizerAPIDefaultDNSPeers(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "cross_namespace")
+	synthesizer := NewPoliciesSynthesizer()
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	frontendEgress := netpols[2].Spec.Egress
+	dnsRule := frontendEgress[len(frontendEgress)-1]
+	require.Len(t, dnsRule.Ports, 1)
+	require.Equal(t, []network.NetworkPolicyPeer{{NamespaceSelector: &metaV1.LabelSelector{}}}, dnsRule.To) // any pod in the cluster
+}
+
+func TestPoliciesSynthesizerAPIIngressControllerPeer(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "gateway_api")
+	synthesizer := NewPoliciesSynthesizer(WithIngressControllerPeer("ingress", map[string]string{"app": "router"}))
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	routerPeer := network.NetworkPolicyPeer{
+		NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "ingress"}},
+		PodSelector:       &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "router"}},
+	}
+	require.Len(t, netpols, 8)
+	for _, netpol := range netpols[:6] { // admin is exposed by an Ingress, all other workloads are exposed by Gateway API routes
+		exposureRule := netpol.Spec.Ingress[len(netpol.Spec.Ingress)-1]
+		require.Equal(t, []network.NetworkPolicyPeer{routerPeer}, exposureRule.From, netpol.Name)
+	}
+}
+
+func TestPoliciesSynthesizerAPIGatewayPeers(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "gateway_api")
+	synthesizer := NewPoliciesSynthesizer(WithGatewayPeers())
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	webIngress := ingressOfNetpol(t, netpols, "web-netpol")
+	require.Len(t, webIngress, 1)
+	gatewayPeer := webIngress[0].From[0]
+	require.Equal(t, map[string]string{"kubernetes.io/metadata.name": "infra"}, gatewayPeer.NamespaceSelector.MatchLabels)
+	require.Equal(t, map[string]string{"gateway.networking.k8s.io/gateway-name": "public"}, gatewayPeer.PodSelector.MatchLabels)
+
+	// TCPRoute, TLSRoute and UDPRoute backends accepted by the Gateway listeners are exposed to the Gateway pods
+	for _, netpolName := range []string{"postgres-netpol", "mqtt-netpol", "syslog-netpol"} {
+		require.Equal(t, []network.NetworkPolicyPeer{gatewayPeer}, ingressOfNetpol(t, netpols, netpolName)[0].From, netpolName)
+	}
+
+	// the Gateway of the api GRPCRoute is not found, and no ingress controller is set
+	apiIngress := ingressOfNetpol(t, netpols, "api-netpol")
+	require.Equal(t, []network.NetworkPolicyPeer{{NamespaceSelector: &metaV1.LabelSelector{}}}, apiIngress[1].From)
+}
+
+func TestPoliciesSynthesizerAPIIstioGatewayPeers(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "istio_gateway")
+	synthesizer := NewPoliciesSynthesizer(WithGatewayPeers(), WithIngressController(IstioIngressGateway))
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	require.Empty(t, synthesizer.Errors())
+
+	istioGatewayPeer := network.NetworkPolicyPeer{
+		NamespaceSelector: &metaV1.LabelSelector{},
+		PodSelector:       &metaV1.LabelSelector{MatchLabels: map[string]string{"istio": "ingressgateway"}},
+	}
+	productpageIngress := ingressOfNetpol(t, netpols, "productpage-netpol")
+	require.Len(t, productpageIngress, 1)
+	require.Equal(t, []network.NetworkPolicyPeer{istioGatewayPeer}, productpageIngress[0].From)
+
+	// the ratings VirtualService is bound to both the mesh and an Istio Gateway, and specifies no destination port
+	ratingsIngress := ingressOfNetpol(t, netpols, "ratings-netpol")
+	require.Len(t, ratingsIngress, 2)
+	require.Equal(t, []network.NetworkPolicyPeer{istioGatewayPeer}, ratingsIngress[1].From)
+	require.Equal(t, int32(9080), ratingsIngress[1].Ports[0].Port.IntVal)
+
+	// the Istio Gateway of the details VirtualService is not found, so the ingress controller pods are used
+	detailsIngress := ingressOfNetpol(t, netpols, "details-netpol")
+	require.Len(t, detailsIngress, 2)
+	require.Equal(t, "istio-system", detailsIngress[1].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+	require.Len(t, detailsIngress[1].Ports, 1)
+	require.Equal(t, int32(9443), detailsIngress[1].Ports[0].Port.IntVal)
+
+	// reviews is only routed to inside the mesh
+	reviewsIngress := ingressOfNetpol(t, netpols, "reviews-netpol")
+	require.Len(t, reviewsIngress, 1)
+	require.Equal(t, "productpage", reviewsIngress[0].From[0].PodSelector.MatchLabels["app"])
+}
+
+func TestPoliciesSynthesizerAPIPrometheusPods(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "prometheus_monitors")
+	synthesizer := NewPoliciesSynthesizer(WithPrometheusPods("observability", map[string]string{"app": "prometheus"}))
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	errs := synthesizer.Errors()
+	require.Len(t, errs, 1)
+	monitorErr := &MonitorTargetNotFoundError{}
+	require.True(t, errors.As(errs[0].Error(), &monitorErr)) // the payments ServiceMonitor selects no service
+
+	prometheusPeer := network.NetworkPolicyPeer{
+		NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "observability"}},
+		PodSelector:       &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+	}
+	scrapedPorts := map[string]int32{
+		"frontend-netpol": 9100, // a ServiceMonitor in another namespace, selecting the frontend-metrics service
+		"cart-netpol":     9121, // a PodMonitor, selecting a named container port
+		"orders-netpol":   8081, // scrape annotations
+	}
+	for netpolName, port := range scrapedPorts {
+		ingress := ingressOfNetpol(t, netpols, netpolName)
+		scrapeRule := ingress[len(ingress)-1]
+		require.Equal(t, []network.NetworkPolicyPeer{prometheusPeer}, scrapeRule.From, netpolName)
+		require.Len(t, scrapeRule.Ports, 1)
+		require.Equal(t, port, scrapeRule.Ports[0].Port.IntVal, netpolName)
+	}
+}
+
+func TestPoliciesSynthesizerAPIPodMonitorScrapedPorts(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "prometheus_monitors")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	var cart *Resource
+	for _, conn := range conns {
+		require.Equal(t, service, conn.Link.Resource.Kind) // PodMonitors are not connection links
+		if conn.Target != nil && conn.Target.Resource.Name == "cart" {
+			cart = conn.Target
+		}
+	}
+	require.NotNil(t, cart)
+	require.Len(t, cart.Resource.scrapedPorts, 1)
+	require.Equal(t, 9121, cart.Resource.scrapedPorts[0].Port)
+	require.True(t, cart.Resource.scrapedPorts[0].exposeToCluster)
+}
+
+func TestPoliciesSynthesizerAPIExternalServices(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "external_services")
+	synthesizer := NewPoliciesSynthesizer() // connections through services are returned even without WithExternalConnections()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	externalTargets := map[string][]ExternalEndpoint{} // for each service, the external endpoints reached through it
+	for _, conn := range conns {
+		require.NotNil(t, conn.Source)
+		if conn.ExternalTarget != nil {
+			require.NotNil(t, conn.Link)
+			require.Nil(t, conn.Target)
+			externalTargets[conn.Link.Resource.Name] = append(externalTargets[conn.Link.Resource.Name], *conn.ExternalTarget)
+		} else { // selector-less services are not in front of any workload
+			require.Contains(t, []string{"api", "elastic"}, conn.Link.Resource.Name)
+		}
+		if conn.Target != nil && conn.Target.Resource.Name == "elastic" { // resolved from the in-cluster name of the ExternalName
+			require.Equal(t, "reports", conn.Source.Resource.Name)
+			require.Equal(t, "search", conn.Link.Resource.Namespace)
+			require.Equal(t, 9200, conn.Source.Resource.UsedPorts[0].Port)
+		}
+	}
+	require.NotContains(t, externalTargets, "search")
+	require.ElementsMatch(t, []ExternalEndpoint{{Host: "mydb.rds.amazonaws.com", Port: 5432}, {Host: "mydb.rds.amazonaws.com"}},
+		externalTargets["db"])
+	require.ElementsMatch(t, []ExternalEndpoint{{IPBlock: "10.20.0.15/32", Port: 80}, {IPBlock: "10.20.0.16/32", Port: 80}},
+		externalTargets["legacy-crm"])
+	require.Equal(t, []ExternalEndpoint{{IPBlock: "192.168.10.5/32", Port: 6379}}, externalTargets["ext-cache"])
+
+	netpols, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+	egress := egressOfNetpol(t, netpols, "api-netpol")
+	require.Len(t, egress, 4) // the two legacy-crm endpoints, ext-cache and DNS; the db host cannot be allowed by a netpol
+	require.Equal(t, "10.20.0.15/32", egress[0].To[0].IPBlock.CIDR)
+	require.Equal(t, int32(80), egress[0].Ports[0].Port.IntVal)
+
+	reportsEgress := egressOfNetpol(t, netpols, "reports-netpol")
+	require.Len(t, reportsEgress, 3) // api, elastic and DNS
+	require.Equal(t, "search", reportsEgress[1].To[0].NamespaceSelector.MatchLabels[namespaceNameLabel])
+	require.Equal(t, int32(9200), reportsEgress[1].Ports[0].Port.IntVal)
+}
+
+func TestPoliciesSynthesizerAPIGatewayRouteErrors(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "gateway_api")
+	synthesizer := NewPoliciesSynthesizer(WithGatewayPeers())
+	_, err := synthesizer.PoliciesFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	var missingGrant *MissingReferenceGrantError
+	var notAccepted *RouteNotAcceptedError
+	missingGrants, notAcceptedRoutes := 0, 0
+	for _, fileErr := range synthesizer.Errors() {
+		require.False(t, fileErr.IsFatal())
+		switch {
+		case errors.As(fileErr.Error(), &missingGrant):
+			missingGrants++
+		case errors.As(fileErr.Error(), &notAccepted):
+			notAcceptedRoutes++
+		}
+	}
+	require.Equal(t, 1, missingGrants)     // the web-tcp TCPRoute in infra refers to store/web without a ReferenceGrant
+	require.Equal(t, 1, notAcceptedRoutes) // the http listener does not accept the syslog UDPRoute
+}
+
 func TestPoliciesSynthesizerAPIDnsNamedPort(t *testing.T) {
 	dirPath := filepath.Join(getTestsDir(), "acs-security-demos")
 	synthesizer := NewPoliciesSynthesizer(WithDNSNamedPort("dns"))
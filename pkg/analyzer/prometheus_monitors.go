@@ -0,0 +1,187 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The types below are a minimal subset of the prometheus-operator API (monitoring.coreos.com/v1)
+
+type serviceMonitorObject struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              serviceMonitorSpec `json:"spec"`
+}
+
+type serviceMonitorSpec struct {
+	Selector          metaV1.LabelSelector     `json:"selector"`
+	NamespaceSelector monitorNamespaceSelector `json:"namespaceSelector,omitempty"`
+	Endpoints         []serviceMonitorEndpoint `json:"endpoints,omitempty"`
+}
+
+type serviceMonitorEndpoint struct {
+	Port       string              `json:"port,omitempty"`       // the name of a service port
+	TargetPort *intstr.IntOrString `json:"targetPort,omitempty"` // the target port of the service (deprecated)
+}
+
+type podMonitorObject struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              podMonitorSpec `json:"spec"`
+}
+
+type podMonitorSpec struct {
+	Selector            metaV1.LabelSelector     `json:"selector"`
+	NamespaceSelector   monitorNamespaceSelector `json:"namespaceSelector,omitempty"`
+	PodMetricsEndpoints []podMetricsEndpoint     `json:"podMetricsEndpoints,omitempty"`
+}
+
+type podMetricsEndpoint struct {
+	Port       *string             `json:"port,omitempty"`       // the name of a container port
+	PortNumber *int32              `json:"portNumber,omitempty"` // the number of a container port
+	TargetPort *intstr.IntOrString `json:"targetPort,omitempty"` // the name or the number of a container port (deprecated)
+}
+
+// serviceMonitor is a ServiceMonitor, along with the file it was found in
+type serviceMonitor struct {
+	serviceMonitorObject
+	filePath string
+}
+
+// podMonitor is a PodMonitor, along with the file it was found in
+type podMonitor struct {
+	podMonitorObject
+	filePath string
+}
+
+// monitorNamespaceSelector selects the namespaces of the monitored services or pods.
+// By default, only the namespace of the monitor is selected.
+type monitorNamespaceSelector struct {
+	Any        bool     `json:"any,omitempty"`
+	MatchNames []string `json:"matchNames,omitempty"`
+}
+
+func (nsSelector *monitorNamespaceSelector) matches(monitorNamespace, namespace string) bool {
+	switch {
+	case nsSelector.Any:
+		return true
+	case len(nsSelector.MatchNames) > 0:
+		return slices.Contains(nsSelector.MatchNames, namespace)
+	}
+	return namespace == monitorNamespace
+}
+
+// selectorMatches returns true if the given label selector matches the given labels (an invalid selector matches nothing)
+func selectorMatches(selector *metaV1.LabelSelector, objLabels map[string]string) bool {
+	labelSelector, err := metaV1.LabelSelectorAsSelector(selector)
+	return err == nil && labelSelector.Matches(labels.Set(objLabels))
+}
+
+// scrapes returns true if the given endpoint of the ServiceMonitor scrapes the given service port
+func (endpoint *serviceMonitorEndpoint) scrapes(port *SvcNetworkAttr) bool {
+	switch {
+	case endpoint.Port != "":
+		return endpoint.Port == port.name
+	case endpoint.TargetPort != nil:
+		targetPort := port.TargetPort
+		if targetPort.Type == intstr.Int && targetPort.IntVal == 0 {
+			targetPort = intstr.FromInt(port.Port) // the target port defaults to the service port
+		}
+		return *endpoint.TargetPort == targetPort
+	}
+	return false
+}
+
+// exposeMonitoredServices marks the service ports scraped by ServiceMonitors as exposed to the Prometheus pods.
+// It returns warnings for ServiceMonitors which do not scrape any port of the given services.
+func exposeMonitoredServices(monitors []*serviceMonitor, services []*Service, logger Logger) []FileProcessingError {
+	warnings := []FileProcessingError{}
+	for _, monitor := range monitors {
+		scraped := false
+		for _, svc := range services {
+			if !monitor.Spec.NamespaceSelector.matches(monitor.Namespace, svc.Resource.Namespace) ||
+				!selectorMatches(&monitor.Spec.Selector, svc.Resource.labels) {
+				continue
+			}
+			for i := range svc.Resource.Network {
+				port := &svc.Resource.Network[i]
+				for j := range monitor.Spec.Endpoints {
+					if monitor.Spec.Endpoints[j].scrapes(port) {
+						port.expose(portExposer{prometheus: true})
+						scraped = true
+					}
+				}
+			}
+		}
+		if !scraped {
+			warnings = appendAndLogNewError(warnings, monitorTargetNotFound(serviceMonitorKind, monitor.Name, monitor.filePath), logger)
+		}
+	}
+	return warnings
+}
+
+// scrapedPort returns the container port of the given workload which the given endpoint of the PodMonitor scrapes
+func (endpoint *podMetricsEndpoint) scrapedPort(res *Resource) (*SvcNetworkAttr, bool) {
+	var port intstr.IntOrString
+	switch {
+	case endpoint.Port != nil:
+		port = intstr.FromString(*endpoint.Port)
+	case endpoint.PortNumber != nil:
+		port = intstr.FromInt32(*endpoint.PortNumber)
+	case endpoint.TargetPort != nil:
+		port = *endpoint.TargetPort
+	default:
+		return nil, false
+	}
+
+	scraped := &SvcNetworkAttr{Protocol: corev1.ProtocolTCP}
+	if declaredPort := res.declaredPort(&SvcNetworkAttr{TargetPort: port}); declaredPort != nil {
+		scraped.Port = int(declaredPort.ContainerPort)
+	} else if port.Type == intstr.Int && len(res.Resource.ContainerPorts) == 0 {
+		scraped.Port = int(port.IntVal) // numbered ports are not checked for workloads declaring no ports
+	} else {
+		return nil, false
+	}
+	scraped.TargetPort = intstr.FromInt(scraped.Port)
+	scraped.expose(portExposer{prometheus: true})
+	return scraped, true
+}
+
+// exposeMonitoredPods marks the container ports scraped by PodMonitors as exposed to the Prometheus pods.
+// It returns warnings for PodMonitors which do not scrape any port of the given workloads.
+func exposeMonitoredPods(monitors []*podMonitor, resources []*Resource, logger Logger) []FileProcessingError {
+	warnings := []FileProcessingError{}
+	for _, monitor := range monitors {
+		scraped := false
+		for _, res := range resources {
+			if !monitor.Spec.NamespaceSelector.matches(monitor.Namespace, res.Resource.Namespace) ||
+				!selectorMatches(&monitor.Spec.Selector, res.Resource.Labels) {
+				continue
+			}
+			for i := range monitor.Spec.PodMetricsEndpoints {
+				port, ok := monitor.Spec.PodMetricsEndpoints[i].scrapedPort(res)
+				if !ok {
+					continue
+				}
+				scraped = true
+				if !slices.ContainsFunc(res.Resource.scrapedPorts, func(p SvcNetworkAttr) bool { return p.Port == port.Port }) {
+					res.Resource.scrapedPorts = append(res.Resource.scrapedPorts, *port)
+				}
+			}
+		}
+		if !scraped {
+			warnings = appendAndLogNewError(warnings, monitorTargetNotFound(podMonitorKind, monitor.Name, monitor.filePath), logger)
+		}
+	}
+	return warnings
+}
@@ -10,7 +10,10 @@ import (
 	"fmt"
 	"slices"
 
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	"github.com/np-guard/netpol-analyzer/pkg/manifests/fsscanner"
 )
@@ -27,15 +30,27 @@ const (
 	cronJob               string = "CronJob"
 	service               string = "Service"
 	configmap             string = "ConfigMap"
+	secretKind            string = "Secret"
 	route                 string = "Route"
 	ingress               string = "Ingress"
 	httpRoute             string = "HTTPRoute"
 	grpcRoute             string = "GRPCRoute"
+	tcpRoute              string = "TCPRoute"
+	tlsRoute              string = "TLSRoute"
+	udpRoute              string = "UDPRoute"
+	gatewayKind           string = "Gateway"
+	referenceGrant        string = "ReferenceGrant"
+	virtualService        string = "VirtualService"
+	serviceMonitorKind    string = "ServiceMonitor"
+	podMonitorKind        string = "PodMonitor"
+	endpointSliceKind     string = "EndpointSlice"
+	endpointsKind         string = "Endpoints"
 )
 
 var (
 	acceptedK8sKinds = []string{pod, replicaSet, replicationController, deployment, daemonSet, statefulSet, job, cronJob,
-		service, configmap, route, ingress, httpRoute, grpcRoute}
+		service, configmap, secretKind, route, ingress, httpRoute, grpcRoute, tcpRoute, tlsRoute, udpRoute, gatewayKind, referenceGrant,
+		virtualService, serviceMonitorKind, podMonitorKind, endpointSliceKind, endpointsKind}
 )
 
 // resourceAccumulator is used to locate all relevant K8s resources in given file-system directories
@@ -44,10 +59,17 @@ type resourceAccumulator struct {
 	logger       Logger
 	stopOn1stErr bool
 
-	workloads        []*Resource      // accumulates all workload resources found
-	services         []*Service       // accumulates all service resources found
-	configmaps       []*cfgMap        // accumulates all ConfigMap resources found
-	servicesToExpose servicesToExpose // stores which services should be later exposed
+	workloads        []*Resource                      // accumulates all workload resources found
+	services         []*Service                       // accumulates all service resources found
+	configmaps       []*cfgMap                        // accumulates all ConfigMap resources found
+	secrets          []*secret                        // accumulates all Secret resources found
+	servicesToExpose servicesToExpose                 // stores which services should be later exposed
+	gateways         []*gateway                       // accumulates all Gateway API Gateways found
+	referenceGrants  []*gatewayv1beta1.ReferenceGrant // accumulates all Gateway API ReferenceGrants found
+	istioGateways    []*istioGateway                  // accumulates all Istio Gateways found
+	serviceMonitors  []*serviceMonitor                // accumulates all prometheus-operator ServiceMonitors found
+	podMonitors      []*podMonitor                    // accumulates all prometheus-operator PodMonitors found
+	endpoints        []*serviceEndpoints              // accumulates the endpoints of all EndpointSlices and Endpoints found
 }
 
 func newResourceAccumulator(logger Logger, failFast bool) *resourceAccumulator {
@@ -92,7 +114,10 @@ func (ra *resourceAccumulator) parseK8sYaml(mfp string) []FileProcessingError {
 func (ra *resourceAccumulator) parseInfos(infos []*resource.Info) []FileProcessingError {
 	parseErrors := []FileProcessingError{}
 	for _, info := range infos {
-		err := ra.parseInfo(info)
+		warnings, err := ra.parseInfo(info)
+		for _, warning := range warnings {
+			parseErrors = appendAndLogNewError(parseErrors, warning, ra.logger)
+		}
 		if err != nil {
 			kind := "<unknown>"
 			if info != nil && info.Object != nil {
@@ -108,26 +133,31 @@ func (ra *resourceAccumulator) parseInfos(infos []*resource.Info) []FileProcessi
 	return parseErrors
 }
 
-// parseInfo takes an Info object, parses it into a K8s resource and puts it into one of the 3 struct slices:
-// the workload resource slice, the Service resource slice and the ConfigMaps resource slice
-// It also updates the set of services to be exposed when parsing Ingress or OpenShift Routes
-func (ra *resourceAccumulator) parseInfo(info *resource.Info) error {
+// parseInfo takes an Info object, parses it into a K8s resource and puts it into one of the 4 struct slices:
+// the workload resource slice, the Service resource slice, the ConfigMaps resource slice and the Secrets resource slice
+// It also updates the set of services to be exposed when parsing Ingress, OpenShift Routes, Gateway API routes
+// or Istio VirtualServices, and accumulates the endpoints of selector-less services from EndpointSlices and Endpoints.
+// Besides an error for a resource which cannot be parsed, it returns warnings for resources which were parsed.
+func (ra *resourceAccumulator) parseInfo(info *resource.Info) ([]*FileProcessingError, error) {
 	if info == nil || info.Object == nil {
-		return fmt.Errorf("a bad Info object - Object field is Nil")
+		return nil, fmt.Errorf("a bad Info object - Object field is Nil")
 	}
 
-	kind := info.Object.GetObjectKind().GroupVersionKind().Kind
-	if !slices.Contains(acceptedK8sKinds, kind) {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	kind := gvk.Kind
+	if !slices.Contains(acceptedK8sKinds, kind) ||
+		(kind == gatewayKind && gvk.Group != gatewayv1.GroupName && gvk.Group != istioNetworkingGroup) {
 		msg := fmt.Sprintf("skipping object with type: %s", kind)
 		resourcePath := info.Source
 		if resourcePath != "" {
 			msg = fmt.Sprintf("in file: %s, %s", resourcePath, msg)
 		}
 		ra.logger.Infof(msg)
-		return nil
+		return nil, nil
 	}
 
 	var err error
+	var warnings []*FileProcessingError
 	switch kind {
 	case service:
 		var svc *Service
@@ -143,61 +173,200 @@ func (ra *resourceAccumulator) parseInfo(info *resource.Info) error {
 		err = gatewayHTTPRouteFromInfo(info, ra.servicesToExpose)
 	case grpcRoute:
 		err = gatewayGRPCRouteFromInfo(info, ra.servicesToExpose)
+	case tcpRoute:
+		err = gatewayTCPRouteFromInfo(info, ra.servicesToExpose)
+	case tlsRoute:
+		err = gatewayTLSRouteFromInfo(info, ra.servicesToExpose)
+	case udpRoute:
+		err = gatewayUDPRouteFromInfo(info, ra.servicesToExpose)
+	case gatewayKind:
+		if gvk.Group == istioNetworkingGroup {
+			var gw *istioGateway
+			gw, err = istioGatewayFromInfo(info)
+			if err == nil {
+				ra.istioGateways = append(ra.istioGateways, gw)
+			}
+			break
+		}
+		var gw *gateway
+		gw, err = gatewayFromInfo(info)
+		if err == nil {
+			ra.gateways = append(ra.gateways, gw)
+		}
+	case virtualService:
+		err = istioVirtualServiceFromInfo(info, ra.servicesToExpose)
+	case serviceMonitorKind:
+		var monitor *serviceMonitor
+		monitor, err = serviceMonitorFromInfo(info)
+		if err == nil {
+			ra.serviceMonitors = append(ra.serviceMonitors, monitor)
+		}
+	case podMonitorKind:
+		var monitor *podMonitor
+		monitor, err = podMonitorFromInfo(info)
+		if err == nil {
+			ra.podMonitors = append(ra.podMonitors, monitor)
+		}
+	case endpointSliceKind:
+		var eps *serviceEndpoints
+		eps, err = k8sEndpointSliceFromInfo(info)
+		if err == nil && eps != nil {
+			ra.endpoints = append(ra.endpoints, eps)
+		}
+	case endpointsKind:
+		var eps *serviceEndpoints
+		eps, err = k8sEndpointsFromInfo(info)
+		if err == nil {
+			ra.endpoints = append(ra.endpoints, eps)
+		}
+	case referenceGrant:
+		var grant *gatewayv1beta1.ReferenceGrant
+		grant, err = referenceGrantFromInfo(info)
+		if err == nil {
+			ra.referenceGrants = append(ra.referenceGrants, grant)
+		}
 	case configmap:
 		var cfgmap *cfgMap
 		cfgmap, err = k8sConfigmapFromInfo(info)
 		if err == nil {
 			ra.configmaps = append(ra.configmaps, cfgmap)
 		}
+	case secretKind:
+		var sec *secret
+		sec, err = k8sSecretFromInfo(info)
+		if err == nil {
+			ra.secrets = append(ra.secrets, sec)
+		}
 	default:
 		var wl *Resource
 		wl, err = k8sWorkloadObjectFromInfo(info)
 		if err == nil {
 			ra.workloads = append(ra.workloads, wl)
+			warnings = wl.Resource.annotationErrors
 		}
 	}
 
-	return err
+	return warnings, err
 }
 
-// inlineConfigMapRefsAsEnvs appends to the Envs of each given resource the ConfigMap values it is referring to
+// inlineConfigMapRefsAsEnvs appends to the Envs of each given resource the ConfigMap and Secret values it is referring to.
+// It also expands "$(VAR)" references in envs, args and command, as variables may take their values from ConfigMaps/Secrets.
 // It should only be called after ALL calls to getRelevantK8sResources successfully returned
 func (ra *resourceAccumulator) inlineConfigMapRefsAsEnvs() []FileProcessingError {
-	cfgMapsByName := map[string]*cfgMap{}
+	cfgMapsByName := map[string]map[string]string{}
+	cfgMapFiles := map[string]string{}
 	for _, cm := range ra.configmaps {
-		cfgMapsByName[cm.FullName] = cm
+		cfgMapsByName[cm.FullName] = cm.Data
+		cfgMapFiles[cm.FullName] = cm.FilePath
+	}
+	secretsByName := map[string]map[string]string{}
+	secretFiles := map[string]string{}
+	for _, sec := range ra.secrets {
+		secretsByName[sec.FullName] = sec.Data
+		secretFiles[sec.FullName] = sec.FilePath
 	}
 
 	parseErrors := []FileProcessingError{}
 	for _, res := range ra.workloads {
-		// inline the envFrom field in PodSpec->containers
-		for _, cfgMapRef := range res.Resource.ConfigMapRefs {
-			configmapFullName := res.Resource.Namespace + "/" + cfgMapRef
-			if cfgMap, ok := cfgMapsByName[configmapFullName]; ok {
-				for _, v := range cfgMap.Data {
-					if netAddr, ok := networkAddressFromStr(v); ok {
-						res.Resource.NetworkAddrs = append(res.Resource.NetworkAddrs, netAddr)
+		cfgMapRefs := dataRefs{configmap, res.Resource.ConfigMapRefs, res.Resource.ConfigMapKeyRefs, cfgMapFiles,
+			configMapNotFound, configMapKeyNotFound, false}
+		parseErrors = ra.inlineDataRefs(res, &cfgMapRefs, cfgMapsByName, res.addNetworkAddr, parseErrors)
+
+		secretRefs := dataRefs{secretKind, res.Resource.SecretRefs, res.Resource.SecretKeyRefs, secretFiles,
+			secretNotFound, secretKeyNotFound, true} // Secrets are normally not committed along with the application manifests
+		parseErrors = ra.inlineDataRefs(res, &secretRefs, secretsByName, res.addSecretNetworkAddr, parseErrors)
+
+		expandVarRefs(res, cfgMapsByName, secretsByName)
+	}
+	return parseErrors
+}
+
+// dataRefs holds a workload's references to either ConfigMaps or Secrets, and how to report missing ones
+type dataRefs struct {
+	kind        string
+	refs        []cfgMapRef
+	keyRefs     []cfgMapKeyRef
+	filePaths   map[string]string // the file of each ConfigMap/Secret, by its full name
+	notFound    func(name, resourceName string) *FileProcessingError
+	keyNotFound func(name, key, resourceName string) *FileProcessingError
+	quiet       bool // missing ConfigMaps/Secrets are only logged at debug level, and are not reported as errors
+}
+
+// reportNotFound reports a missing ConfigMap/Secret, referenced by a non-optional reference of the given workload
+func (ra *resourceAccumulator) reportNotFound(refs *dataRefs, fullName, resourceName string,
+	parseErrors []FileProcessingError) []FileProcessingError {
+	err := refs.notFound(fullName, resourceName)
+	if refs.quiet {
+		ra.logger.Debugf("%v", err.Error())
+		return parseErrors
+	}
+	return appendAndLogNewError(parseErrors, err, ra.logger)
+}
+
+// inlineDataRefs looks for network addresses in the data referenced by the given workload, and adds them using addAddr
+func (ra *resourceAccumulator) inlineDataRefs(res *Resource, refs *dataRefs, dataByName map[string]map[string]string,
+	addAddr func(string, ...AddressEvidence), parseErrors []FileProcessingError) []FileProcessingError {
+	// inline the envFrom field in PodSpec->containers (and data mounted as volumes)
+	for _, ref := range refs.refs {
+		fullName := res.Resource.Namespace + "/" + ref.Name
+		if data, ok := dataByName[fullName]; ok {
+			for k, v := range data {
+				if res.isIgnoredEnv(k) {
+					continue // keys of ConfigMaps/Secrets used in envFrom are env var names
+				}
+				for _, netAddr := range networkAddressesFromStr(v) {
+					evidence := []AddressEvidence{}
+					for _, container := range ref.Containers {
+						evidence = append(evidence, AddressEvidence{FilePath: refs.filePaths[fullName], Container: container,
+							Location: dataKeyLocation(refs.kind, ref.Name, k, ""), RawValue: v})
 					}
+					addAddr(netAddr, evidence...)
 				}
-			} else {
-				parseErrors = appendAndLogNewError(parseErrors, configMapNotFound(configmapFullName, res.Resource.Name), ra.logger)
 			}
+		} else if !ref.optional {
+			parseErrors = ra.reportNotFound(refs, fullName, res.Resource.Name, parseErrors)
 		}
+	}
 
-		// inline PodSpec->container->env->valueFrom->configMapKeyRef
-		for _, cfgMapKeyRef := range res.Resource.ConfigMapKeyRefs {
-			configmapFullName := res.Resource.Namespace + "/" + cfgMapKeyRef.Name
-			cfgMap, ok := cfgMapsByName[configmapFullName]
-			if !ok {
-				parseErrors = appendAndLogNewError(parseErrors, configMapNotFound(configmapFullName, res.Resource.Name), ra.logger)
-				continue
+	// inline PodSpec->container->env->valueFrom->configMapKeyRef (or secretKeyRef)
+	for _, keyRef := range refs.keyRefs {
+		if res.isIgnoredEnv(keyRef.EnvName) {
+			continue
+		}
+		fullName := res.Resource.Namespace + "/" + keyRef.Name
+		data, ok := dataByName[fullName]
+		if !ok {
+			if !keyRef.optional {
+				parseErrors = ra.reportNotFound(refs, fullName, res.Resource.Name, parseErrors)
+			}
+			continue
+		}
+		if val, ok := data[keyRef.Key]; ok {
+			for _, netAddr := range networkAddressesFromStr(val) {
+				addAddr(netAddr, AddressEvidence{FilePath: refs.filePaths[fullName], Container: keyRef.Container,
+					Location: dataKeyLocation(refs.kind, keyRef.Name, keyRef.Key, keyRef.EnvName), RawValue: val})
 			}
-			if val, ok := cfgMap.Data[cfgMapKeyRef.Key]; ok {
-				if netAddr, ok := networkAddressFromStr(val); ok {
-					res.Resource.NetworkAddrs = append(res.Resource.NetworkAddrs, netAddr)
+		} else if !keyRef.optional {
+			err := refs.keyNotFound(keyRef.Name, keyRef.Key, res.Resource.Name)
+			parseErrors = appendAndLogNewError(parseErrors, err, ra.logger)
+		}
+	}
+	return parseErrors
+}
+
+// checkServiceTargetPorts warns about services targeting named ports which are not declared by the workloads they select.
+// Numbered target ports are not checked, because declaring container ports is optional (and a partial list is common).
+// It should only be called after ALL calls to getRelevantK8sResources successfully returned
+func (ra *resourceAccumulator) checkServiceTargetPorts() []FileProcessingError {
+	parseErrors := []FileProcessingError{}
+	for _, wl := range ra.workloads {
+		for _, svc := range findServices(wl, ra.services) {
+			for i := range svc.Resource.Network {
+				port := &svc.Resource.Network[i]
+				if port.TargetPort.Type == intstr.Int || wl.declaredPort(port) != nil {
+					continue
 				}
-			} else {
-				err := configMapKeyNotFound(cfgMapKeyRef.Name, cfgMapKeyRef.Key, res.Resource.Name)
+				err := undeclaredTargetPort(svc.Resource.Name, port.TargetPort.StrVal, wl.Resource.Name, svc.Resource.FilePath)
 				parseErrors = appendAndLogNewError(parseErrors, err, ra.logger)
 			}
 		}
@@ -207,8 +376,11 @@ func (ra *resourceAccumulator) inlineConfigMapRefsAsEnvs() []FileProcessingError
 
 // exposeServices changes the exposure of services pointed by resources such as Route or Ingress.
 // This will ensure that the network policy for their workloads will allow ingress from all the cluster or from the outside internet.
+// It returns warnings for Gateway API routes which cannot expose their backends (see resolveExposer()).
 // It should only be called after ALL calls to getRelevantK8sResources successfully returned
-func (ra *resourceAccumulator) exposeServices() {
+func (ra *resourceAccumulator) exposeServices() []FileProcessingError {
+	errs := []FileProcessingError{}
+	reported := map[string]bool{} // each route may refer to the same service port several times
 	for _, svc := range ra.services {
 		exposedServicesInNamespace, ok := ra.servicesToExpose[svc.Resource.Namespace]
 		if !ok {
@@ -220,11 +392,54 @@ func (ra *resourceAccumulator) exposeServices() {
 		}
 		for i := range svc.Resource.Network {
 			port := &svc.Resource.Network[i]
-			for _, portToExpose := range portsToExpose {
-				if port.equals(portToExpose) {
-					port.exposeToCluster = true
+			for j := range portsToExpose {
+				if portsToExpose[j].port != nil && !port.equals(portsToExpose[j].port) {
+					continue
+				}
+				exposer, err := ra.resolveExposer(svc, &portsToExpose[j])
+				if err != nil {
+					if msg := err.Error().Error(); !reported[msg] {
+						reported[msg] = true
+						errs = appendAndLogNewError(errs, err, ra.logger)
+					}
+					continue
 				}
+				port.expose(exposer)
 			}
 		}
 	}
+	return errs
+}
+
+// resolveExposer returns what exposes the given service port. A Gateway API route only exposes a service in another namespace
+// if a ReferenceGrant allows it, and only exposes it through a Gateway if one of the Gateway listeners accepts the route.
+// Routes attached to Gateways which were not found (Gateway API or Istio ones) are assumed to be exposed by an ingress controller.
+func (ra *resourceAccumulator) resolveExposer(svc *Service, port *exposedPort) (portExposer, *FileProcessingError) {
+	if port.exposer.istioGateway != "" {
+		idx := slices.IndexFunc(ra.istioGateways, func(gw *istioGateway) bool { return gw.fullName() == port.exposer.istioGateway })
+		if idx < 0 {
+			return portExposer{ingressController: true}, nil
+		}
+		return portExposer{istioGateway: port.exposer.istioGateway, istioGatewayPods: ra.istioGateways[idx]}, nil
+	}
+	route := port.route
+	if route != nil && !referenceGranted(route, svc.Resource.Namespace, svc.Resource.Name, ra.referenceGrants) {
+		svcName := svc.Resource.Namespace + "/" + svc.Resource.Name
+		return portExposer{}, missingReferenceGrant(route.kind, route.name, svcName, route.filePath)
+	}
+	if port.exposer.gateway == "" {
+		return port.exposer, nil
+	}
+	idx := slices.IndexFunc(ra.gateways, func(gw *gateway) bool { return gw.fullName() == port.exposer.gateway })
+	if idx < 0 {
+		return portExposer{ingressController: true}, nil
+	}
+	if !ra.gateways[idx].accepts(route, port.parentRef) {
+		gatewayName := port.exposer.gateway
+		if port.parentRef.SectionName != nil {
+			gatewayName += fmt.Sprintf(" (listener %s)", *port.parentRef.SectionName)
+		}
+		return portExposer{}, routeNotAccepted(route.kind, route.name, gatewayName, route.filePath)
+	}
+	return port.exposer, nil
 }
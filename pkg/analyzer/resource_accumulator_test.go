@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 func TestParseK8sYamlBadYamlDocument(t *testing.T) {
@@ -82,3 +83,37 @@ func TestParseK8sYamlNonK8sResources(t *testing.T) {
 	errs := resAcc.parseK8sYaml(dirPath)
 	require.Empty(t, errs) // Irrelevant resources such as Certificate are only reported to log - not returned as errors
 }
+
+func TestParseK8sYamlGatewayListenersAndReferenceGrants(t *testing.T) {
+	yamlPath := filepath.Join(getTestsDir(), "gateway_api", "app.yaml")
+	resAcc := newResourceAccumulator(NewDefaultLogger(), false)
+	errs := resAcc.parseK8sYaml(yamlPath)
+	require.Empty(t, errs)
+	require.Len(t, resAcc.gateways, 1)
+	require.Len(t, resAcc.referenceGrants, 1)
+
+	gw := resAcc.gateways[0]
+	require.Equal(t, "infra/public", gw.fullName())
+	postgresListener := gatewayv1.SectionName("postgres")
+	mqttListener := gatewayv1.SectionName("mqtt")
+	httpPort := gatewayv1.PortNumber(80)
+	toListener := func(section *gatewayv1.SectionName, port *gatewayv1.PortNumber) *gatewayv1.ParentReference {
+		return &gatewayv1.ParentReference{Name: "public", SectionName: section, Port: port}
+	}
+
+	dataTCPRoute := &gatewayRoute{kind: tcpRoute, name: "postgres", namespace: "data"}
+	require.True(t, gw.accepts(dataTCPRoute, toListener(nil, nil)))
+	require.True(t, gw.accepts(dataTCPRoute, toListener(&postgresListener, nil)))
+	require.False(t, gw.accepts(dataTCPRoute, toListener(&postgresListener, &httpPort)))
+	require.False(t, gw.accepts(dataTCPRoute, toListener(&mqttListener, nil))) // only routes in the infra namespace are allowed
+	infraTCPRoute := &gatewayRoute{kind: tcpRoute, name: "mqtt", namespace: "infra"}
+	require.True(t, gw.accepts(infraTCPRoute, toListener(&mqttListener, nil)))
+	dataUDPRoute := &gatewayRoute{kind: udpRoute, name: "postgres", namespace: "data"}
+	require.False(t, gw.accepts(dataUDPRoute, toListener(&postgresListener, nil)))
+
+	infraTLSRoute := &gatewayRoute{kind: tlsRoute, name: "mqtt", namespace: "infra"}
+	require.True(t, referenceGranted(infraTLSRoute, "store", "mqtt", resAcc.referenceGrants))
+	require.True(t, referenceGranted(infraTLSRoute, "infra", "mqtt", nil))
+	require.False(t, referenceGranted(infraTLSRoute, "data", "postgres", resAcc.referenceGrants))
+	require.False(t, referenceGranted(infraTCPRoute, "store", "mqtt", resAcc.referenceGrants))
+}
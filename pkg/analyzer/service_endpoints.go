@@ -0,0 +1,192 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// serviceEndpoints holds the addresses and the ports of a selector-less service, as given by its EndpointSlices or Endpoints
+type serviceEndpoints struct {
+	namespace string
+	service   string
+	addresses []string // IP addresses, or host names (for EndpointSlices with the FQDN address type)
+	ports     []endpointPort
+}
+
+type endpointPort struct {
+	name string
+	port int
+}
+
+func (eps *serviceEndpoints) addAddress(address string) {
+	if address != "" && !slices.Contains(eps.addresses, address) {
+		eps.addresses = append(eps.addresses, address)
+	}
+}
+
+func (eps *serviceEndpoints) addPort(port endpointPort) {
+	if !slices.Contains(eps.ports, port) {
+		eps.ports = append(eps.ports, port)
+	}
+}
+
+// merge adds the addresses and the ports of the given endpoints (e.g., of another EndpointSlice of the same service)
+func (eps *serviceEndpoints) merge(other *serviceEndpoints) {
+	for _, address := range other.addresses {
+		eps.addAddress(address)
+	}
+	for _, port := range other.ports {
+		eps.addPort(port)
+	}
+}
+
+// endpointPortOf returns the port number of the endpoints which the given service port targets.
+// As in K8s, service ports are mapped to endpoint ports by name. If no endpoint port matches, the single endpoint port
+// is used, and then the target port of the service.
+func (eps *serviceEndpoints) endpointPortOf(svcPort *SvcNetworkAttr) int {
+	idx := slices.IndexFunc(eps.ports, func(port endpointPort) bool { return port.name == svcPort.name })
+	switch {
+	case idx >= 0:
+		return eps.ports[idx].port
+	case len(eps.ports) == 1:
+		return eps.ports[0].port
+	case svcPort.TargetPort.Type == intstr.Int && svcPort.TargetPort.IntVal > 0:
+		return int(svcPort.TargetPort.IntVal)
+	}
+	return svcPort.Port
+}
+
+// externalEndpoints returns the external endpoints a source using the given ports of the given service actually connects to.
+// These are the external host of an ExternalName service, or the addresses of the EndpointSlices/Endpoints of a selector-less
+// service. No used ports means all the service ports (or any port, for services declaring no ports).
+func (svc *Service) externalEndpoints(usedPorts []SvcNetworkAttr) []ExternalEndpoint {
+	if len(usedPorts) == 0 {
+		usedPorts = svc.Resource.Network
+	}
+	if len(usedPorts) == 0 {
+		usedPorts = []SvcNetworkAttr{{}}
+	}
+
+	endpoints := []ExternalEndpoint{}
+	for i := range usedPorts {
+		port := &usedPorts[i]
+		if svc.Resource.ExternalName != "" {
+			endpoints = append(endpoints, ExternalEndpoint{Host: strings.TrimSuffix(svc.Resource.ExternalName, "."), Port: port.Port})
+			continue
+		}
+		if svc.Resource.endpoints == nil {
+			continue
+		}
+		portNum := port.Port // services declaring no ports are used with the port of the endpoints
+		if port.Port > 0 && len(svc.Resource.Network) > 0 {
+			portNum = svc.Resource.endpoints.endpointPortOf(port)
+		}
+		for _, address := range svc.Resource.endpoints.addresses {
+			if net.ParseIP(address) != nil {
+				endpoints = append(endpoints, ExternalEndpoint{IPBlock: hostCIDR(address), Port: portNum})
+			} else {
+				endpoints = append(endpoints, ExternalEndpoint{Host: address, Port: portNum})
+			}
+		}
+	}
+	return endpoints
+}
+
+// attachServiceEndpoints sets the endpoints of each selector-less service to the given EndpointSlices/Endpoints of the service.
+// Endpoints of services with selectors are managed by K8s, and are ignored.
+func attachServiceEndpoints(services []*Service, endpoints []*serviceEndpoints) {
+	for _, svc := range services {
+		if len(svc.Resource.Selectors) > 0 || svc.Resource.ExternalName != "" {
+			continue
+		}
+		for _, eps := range endpoints {
+			if eps.namespace != svc.Resource.Namespace || eps.service != svc.Resource.Name {
+				continue
+			}
+			if svc.Resource.endpoints == nil {
+				svc.Resource.endpoints = &serviceEndpoints{namespace: eps.namespace, service: eps.service}
+			}
+			svc.Resource.endpoints.merge(eps)
+		}
+	}
+}
+
+// discoverServiceEgressConnections returns an "external egress" connection from each workload using an ExternalName service
+// or a selector-less service with endpoints, to each of the external endpoints behind the service.
+// The Link of such connections is the service the workload uses.
+// An ExternalName service pointing at an in-cluster service ("<svc>.<ns>.svc.<cluster-domain>") is resolved instead:
+// workloads using it get regular connections to the workloads behind the in-cluster service (which is their Link).
+func discoverServiceEgressConnections(resources []*Resource, services []*Service, clusterDomain string) []*Connections {
+	namespaces := namespacesOf(resources, services)
+	connections := []*Connections{}
+	for _, svc := range services {
+		if svc.Resource.ExternalName == "" && svc.Resource.endpoints == nil {
+			continue
+		}
+		var inClusterSvc *Service
+		if svc.Resource.ExternalName != "" && !isExternalHostName(svc.Resource.ExternalName, clusterDomain, namespaces) {
+			if inClusterSvc = findServiceByFQDN(services, svc.Resource.ExternalName, clusterDomain); inClusterSvc == nil {
+				continue // an in-cluster name, but not of any of the analyzed services
+			}
+		}
+		for _, src := range findSource(resources, svc, nil, clusterDomain) {
+			if inClusterSvc != nil {
+				connections = append(connections, inClusterServiceConnections(src, inClusterSvc, resources)...)
+				continue
+			}
+			for _, endpoint := range svc.externalEndpoints(src.Resource.UsedPorts) {
+				connections = append(connections, &Connections{Source: src, Link: svc, ExternalTarget: &endpoint,
+					SourceContainers: src.Resource.usedByContainers, StartupOnly: isStartupOnly(src.Resource.usedByContainers),
+					Evidence: src.Resource.evidence})
+			}
+		}
+	}
+	return connections
+}
+
+// findServiceByFQDN returns the service whose fully qualified name is the given host name, or nil if there is no such service
+func findServiceByFQDN(services []*Service, host, clusterDomain string) *Service {
+	host = strings.TrimSuffix(host, ".")
+	for _, svc := range services {
+		if host == svc.Resource.Name+"."+svc.Resource.Namespace+".svc."+clusterDomain {
+			return svc
+		}
+	}
+	return nil
+}
+
+// inClusterServiceConnections returns a connection from the given source, which uses an ExternalName service pointing at
+// the given in-cluster service, to each workload behind the in-cluster service.
+// The source connects to the in-cluster service on the same port numbers it uses with the ExternalName service.
+func inClusterServiceConnections(src *Resource, svc *Service, resources []*Resource) []*Connections {
+	foundSrc := *src // We copy the resource so we can specify the ports of the in-cluster service used by the source
+	foundSrc.Resource.UsedPorts = nil
+	for _, usedPort := range src.Resource.UsedPorts {
+		idx := slices.IndexFunc(svc.Resource.Network, func(port SvcNetworkAttr) bool { return port.Port == usedPort.Port })
+		if idx >= 0 {
+			foundSrc.Resource.UsedPorts = append(foundSrc.Resource.UsedPorts, svc.Resource.Network[idx])
+		}
+	}
+	if len(src.Resource.UsedPorts) > 0 && len(foundSrc.Resource.UsedPorts) == 0 {
+		return nil // the in-cluster service has none of the ports used by the source
+	}
+
+	connections := []*Connections{}
+	for _, target := range resources {
+		if !target.equals(src) && slices.Contains(findServices(target, []*Service{svc}), svc) {
+			connections = append(connections, &Connections{Source: &foundSrc, Target: target, Link: svc,
+				SourceContainers: src.Resource.usedByContainers, StartupOnly: isStartupOnly(src.Resource.usedByContainers),
+				Evidence: src.Resource.evidence})
+		}
+	}
+	return connections
+}
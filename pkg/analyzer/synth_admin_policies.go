@@ -0,0 +1,206 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	network "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	adminnetpol "sigs.k8s.io/network-policy-api/apis/v1alpha1"
+)
+
+const (
+	adminPolicyAPIVersion          = "policy.networking.k8s.io/v1alpha1"
+	adminNetworkPolicyKind         = "AdminNetworkPolicy"
+	baselineAdminNetworkPolicyKind = "BaselineAdminNetworkPolicy"
+	baselineAdminNetworkPolicyName = "default" // there can only be one BaselineAdminNetworkPolicy, and it must be named "default"
+
+	// CrossNamespaceAdminPolicyPriority is the priority of the generated AdminNetworkPolicies (lower values take precedence)
+	CrossNamespaceAdminPolicyPriority = 50
+
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	defaultNamespace   = "default" // admin policies are cluster-scoped, so workloads with no namespace are assumed to be in "default"
+
+	adminPolicyRuleNameMaxLength = 100
+)
+
+// getBaselineAdminPolicy generates a BaselineAdminNetworkPolicy, denying all ingress and egress in all the namespaces
+// of the given resources. It replaces the per-namespace default-deny NetworkPolicies, as the generated NetworkPolicies
+// take precedence over it.
+// Note that ingress from outside the cluster cannot be denied by a BaselineAdminNetworkPolicy.
+func getBaselineAdminPolicy(resources []*Resource) *adminnetpol.BaselineAdminNetworkPolicy {
+	namespaces := clusterNamespaces(resources)
+	allNamespaces := &metaV1.LabelSelector{}
+	return &adminnetpol.BaselineAdminNetworkPolicy{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       baselineAdminNetworkPolicyKind,
+			APIVersion: adminPolicyAPIVersion,
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: baselineAdminNetworkPolicyName,
+		},
+		Spec: adminnetpol.BaselineAdminNetworkPolicySpec{
+			Subject: adminnetpol.AdminNetworkPolicySubject{
+				Namespaces: &metaV1.LabelSelector{
+					MatchExpressions: []metaV1.LabelSelectorRequirement{
+						{Key: namespaceNameLabel, Operator: metaV1.LabelSelectorOpIn, Values: namespaces},
+					},
+				},
+			},
+			Ingress: []adminnetpol.BaselineAdminNetworkPolicyIngressRule{{
+				Name:   "default-deny-ingress",
+				Action: adminnetpol.BaselineAdminNetworkPolicyRuleActionDeny,
+				From:   []adminnetpol.AdminNetworkPolicyIngressPeer{{Namespaces: allNamespaces}},
+			}},
+			Egress: []adminnetpol.BaselineAdminNetworkPolicyEgressRule{{
+				Name:   "default-deny-egress",
+				Action: adminnetpol.BaselineAdminNetworkPolicyRuleActionDeny,
+				To: []adminnetpol.AdminNetworkPolicyEgressPeer{
+					{Namespaces: allNamespaces},
+					{Networks: []adminnetpol.CIDR{anyIPv4, anyIPv6}},
+				},
+			}},
+		},
+	}
+}
+
+// synthCrossNamespaceAdminPolicies generates an AdminNetworkPolicy for each workload with connections to/from workloads
+// in other namespaces. The policy allows these connections, so they cannot be blocked by namespace-scoped NetworkPolicies.
+// Connections in which one of the workloads has no namespace are assumed to be within the same namespace.
+// Policies are named "<namespace>.<workload-name>-cross-namespace": as namespace names cannot contain dots, names are unique.
+func (ps *PoliciesSynthesizer) synthCrossNamespaceAdminPolicies(connections []*Connections) []*adminnetpol.AdminNetworkPolicy {
+	crossNsConns := []*Connections{}
+	for _, conn := range connections {
+		if conn.Source != nil && conn.Target != nil && conn.Source.Resource.Namespace != "" && conn.Target.Resource.Namespace != "" &&
+			conn.Source.Resource.Namespace != conn.Target.Resource.Namespace {
+			crossNsConns = append(crossNsConns, conn)
+		}
+	}
+
+	deployConnectivity := ps.determineConnectivityPerDeployment(nil, crossNsConns)
+	policyNames := netpolNamesPerDeployment(deployConnectivity)
+	anps := make([]*adminnetpol.AdminNetworkPolicy, 0, len(deployConnectivity))
+	for _, deployConn := range deployConnectivity {
+		res := &deployConn.Resource.Resource
+		policyName := res.Namespace + "." + strings.TrimSuffix(policyNames[deployConn], "-netpol") + "-cross-namespace"
+		anp := adminnetpol.AdminNetworkPolicy{
+			TypeMeta: metaV1.TypeMeta{
+				Kind:       adminNetworkPolicyKind,
+				APIVersion: adminPolicyAPIVersion,
+			},
+			ObjectMeta: metaV1.ObjectMeta{
+				Name: policyName,
+			},
+			Spec: adminnetpol.AdminNetworkPolicySpec{
+				Priority: CrossNamespaceAdminPolicyPriority,
+				Subject:  adminnetpol.AdminNetworkPolicySubject{Pods: namespacedPod(res.Namespace, getDeployConnSelector(deployConn))},
+			},
+		}
+		for _, rule := range deployConn.ingressConns {
+			if len(rule.From) == 0 {
+				continue // the target is exposed to all sources (e.g., by a LoadBalancer service)
+			}
+			peers := make([]adminnetpol.AdminNetworkPolicyIngressPeer, 0, len(rule.From))
+			for i := range rule.From {
+				peers = append(peers, adminnetpol.AdminNetworkPolicyIngressPeer{Pods: toNamespacedPod(&rule.From[i])})
+			}
+			anp.Spec.Ingress = append(anp.Spec.Ingress, adminnetpol.AdminNetworkPolicyIngressRule{
+				Name:   adminPolicyRuleName("allow-from-", rule.From),
+				Action: adminnetpol.AdminNetworkPolicyRuleActionAllow,
+				From:   peers,
+				Ports:  toAdminPolicyPorts(rule.Ports),
+			})
+		}
+		for _, rule := range deployConn.egressConns {
+			peers := make([]adminnetpol.AdminNetworkPolicyEgressPeer, 0, len(rule.To))
+			for i := range rule.To {
+				peers = append(peers, adminnetpol.AdminNetworkPolicyEgressPeer{Pods: toNamespacedPod(&rule.To[i])})
+			}
+			anp.Spec.Egress = append(anp.Spec.Egress, adminnetpol.AdminNetworkPolicyEgressRule{
+				Name:   adminPolicyRuleName("allow-to-", rule.To),
+				Action: adminnetpol.AdminNetworkPolicyRuleActionAllow,
+				To:     peers,
+				Ports:  toAdminPolicyPorts(rule.Ports),
+			})
+		}
+		anps = append(anps, &anp)
+	}
+	return anps
+}
+
+// clusterNamespaces returns the (sorted) namespaces of the given resources, for use in cluster-scoped policies
+func clusterNamespaces(resources []*Resource) []string {
+	namespaces := []string{}
+	for _, res := range resources {
+		namespace := namespaceOrDefault(res.Resource.Namespace)
+		if !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces
+}
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return defaultNamespace
+	}
+	return namespace
+}
+
+func namespacedPod(namespace string, podSelector *metaV1.LabelSelector) *adminnetpol.NamespacedPod {
+	return &adminnetpol.NamespacedPod{
+		NamespaceSelector: metaV1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: namespace}},
+		PodSelector:       *podSelector,
+	}
+}
+
+// toNamespacedPod converts a NetworkPolicy peer of a workload in another namespace into an admin policy peer
+func toNamespacedPod(peer *network.NetworkPolicyPeer) *adminnetpol.NamespacedPod {
+	return &adminnetpol.NamespacedPod{NamespaceSelector: *peer.NamespaceSelector, PodSelector: *peer.PodSelector}
+}
+
+// adminPolicyRuleName returns a name for an admin policy rule with the given peers (of workloads in other namespaces):
+// the prefix followed by the namespaces of the peers, or by their number if the name would be too long
+func adminPolicyRuleName(prefix string, peers []network.NetworkPolicyPeer) string {
+	namespaces := []string{}
+	for i := range peers {
+		namespace := peers[i].NamespaceSelector.MatchLabels[namespaceNameLabel]
+		if !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	name := prefix + strings.Join(namespaces, ",")
+	if len(name) > adminPolicyRuleNameMaxLength {
+		name = prefix + strconv.Itoa(len(namespaces)) + "-namespaces"
+	}
+	return name
+}
+
+// toAdminPolicyPorts converts NetworkPolicy ports into admin policy ports (nil, meaning all ports, if no ports are given)
+func toAdminPolicyPorts(ports []network.NetworkPolicyPort) *[]adminnetpol.AdminNetworkPolicyPort {
+	if len(ports) == 0 {
+		return nil
+	}
+	adminPorts := make([]adminnetpol.AdminNetworkPolicyPort, 0, len(ports))
+	for _, port := range ports {
+		if port.Port.Type == intstr.String {
+			namedPort := port.Port.StrVal
+			adminPorts = append(adminPorts, adminnetpol.AdminNetworkPolicyPort{NamedPort: &namedPort})
+		} else {
+			adminPorts = append(adminPorts, adminnetpol.AdminNetworkPolicyPort{
+				PortNumber: &adminnetpol.Port{Protocol: *port.Protocol, Port: port.Port.IntVal},
+			})
+		}
+	}
+	return &adminPorts
+}
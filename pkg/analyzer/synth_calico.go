@@ -0,0 +1,187 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	network "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	calicoAPIVersion            = "projectcalico.org/v3"
+	calicoNetworkPolicyKind     = "NetworkPolicy"
+	calicoGlobalPolicyKind      = "GlobalNetworkPolicy"
+	calicoDefaultDenyPolicyName = "default-deny"
+	calicoAllowAction           = "Allow"
+	calicoSelectAll             = "all()"
+)
+
+// The types below are a minimal subset of Calico's NetworkPolicy and GlobalNetworkPolicy API (projectcalico.org/v3)
+
+type calicoPolicy struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              calicoPolicySpec `json:"spec"`
+}
+
+type calicoPolicySpec struct {
+	Selector          string       `json:"selector,omitempty"`
+	NamespaceSelector string       `json:"namespaceSelector,omitempty"`
+	Types             []string     `json:"types"`
+	Ingress           []calicoRule `json:"ingress,omitempty"`
+	Egress            []calicoRule `json:"egress,omitempty"`
+}
+
+type calicoRule struct {
+	Action      string            `json:"action"`
+	Protocol    string            `json:"protocol,omitempty"`
+	Source      *calicoEntityRule `json:"source,omitempty"`
+	Destination *calicoEntityRule `json:"destination,omitempty"`
+}
+
+type calicoEntityRule struct {
+	Nets              []string             `json:"nets,omitempty"`
+	Selector          string               `json:"selector,omitempty"`
+	NamespaceSelector string               `json:"namespaceSelector,omitempty"`
+	Ports             []intstr.IntOrString `json:"ports,omitempty"`
+}
+
+// buildCalicoPolicies generates a Calico NetworkPolicy for each workload, allowing its discovered connections,
+// and a single GlobalNetworkPolicy selecting all workload namespaces, which denies all other traffic
+func (ps *PoliciesSynthesizer) buildCalicoPolicies(resources []*Resource,
+	deployConnectivity []*deploymentConnectivity) []*unstructured.Unstructured {
+	policyNames := netpolNamesPerDeployment(deployConnectivity)
+	policies := []*calicoPolicy{}
+	for _, deployConn := range deployConnectivity {
+		ps.addDNSEgressRule(deployConn)
+		policy := newCalicoPolicy(calicoNetworkPolicyKind, policyNames[deployConn], deployConn.Resource.Resource.Namespace)
+		policy.Spec.Selector = calicoSelector(getDeployConnSelector(deployConn).MatchLabels)
+		for _, rule := range deployConn.ingressConns {
+			for _, peer := range peersOrAny(rule.From) {
+				for _, protocolPorts := range groupPortsByProtocol(rule.Ports) {
+					calicoRule := calicoRule{Action: calicoAllowAction, Protocol: protocolPorts.protocol}
+					if peer != nil {
+						calicoRule.Source = toCalicoEntity(peer)
+					}
+					if len(protocolPorts.ports) > 0 {
+						calicoRule.Destination = &calicoEntityRule{Ports: protocolPorts.ports}
+					}
+					policy.Spec.Ingress = append(policy.Spec.Ingress, calicoRule)
+				}
+			}
+		}
+		for _, rule := range deployConn.egressConns {
+			for i := range rule.To {
+				for _, protocolPorts := range groupPortsByProtocol(rule.Ports) {
+					destination := toCalicoEntity(&rule.To[i])
+					destination.Ports = protocolPorts.ports
+					policy.Spec.Egress = append(policy.Spec.Egress,
+						calicoRule{Action: calicoAllowAction, Protocol: protocolPorts.protocol, Destination: destination})
+				}
+			}
+		}
+		policies = append(policies, policy)
+	}
+
+	defaultDeny := newCalicoPolicy(calicoGlobalPolicyKind, calicoDefaultDenyPolicyName, "")
+	defaultDeny.Spec.NamespaceSelector = fmt.Sprintf("%s in {'%s'}", namespaceNameLabel, strings.Join(clusterNamespaces(resources), "', '"))
+	policies = append(policies, defaultDeny) // selected pods which are not allowed by any policy are denied
+
+	return toUnstructuredObjects(policies, ps.logger)
+}
+
+func newCalicoPolicy(kind, name, namespace string) *calicoPolicy {
+	return &calicoPolicy{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       kind,
+			APIVersion: calicoAPIVersion,
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: calicoPolicySpec{
+			Types: []string{string(network.PolicyTypeIngress), string(network.PolicyTypeEgress)},
+		},
+	}
+}
+
+// peersOrAny returns the given NetworkPolicy peers, or a single nil peer (meaning any peer) if the given slice is empty
+func peersOrAny(peers []network.NetworkPolicyPeer) []*network.NetworkPolicyPeer {
+	if len(peers) == 0 {
+		return []*network.NetworkPolicyPeer{nil}
+	}
+	res := make([]*network.NetworkPolicyPeer, 0, len(peers))
+	for i := range peers {
+		res = append(res, &peers[i])
+	}
+	return res
+}
+
+// toCalicoEntity converts a NetworkPolicy peer into a Calico entity rule
+func toCalicoEntity(peer *network.NetworkPolicyPeer) *calicoEntityRule {
+	entity := calicoEntityRule{}
+	switch {
+	case peer.IPBlock != nil:
+		entity.Nets = []string{peer.IPBlock.CIDR}
+	case peer.PodSelector == nil: // all pods in the namespaces selected by the namespace selector, which is always empty
+		entity.NamespaceSelector = calicoSelectAll
+	default:
+		entity.Selector = calicoSelector(peer.PodSelector.MatchLabels)
+		if peer.NamespaceSelector != nil { // a workload in another namespace
+			entity.NamespaceSelector = calicoSelector(peer.NamespaceSelector.MatchLabels)
+		}
+	}
+	return &entity
+}
+
+// calicoProtocolPorts are ports of a single protocol, as each Calico rule refers to a single protocol
+type calicoProtocolPorts struct {
+	protocol string
+	ports    []intstr.IntOrString
+}
+
+// groupPortsByProtocol groups the given ports by their protocol.
+// If no ports are given, a single group without a protocol and ports is returned (allowing all ports).
+func groupPortsByProtocol(ports []network.NetworkPolicyPort) []calicoProtocolPorts {
+	groups := []calicoProtocolPorts{}
+	for _, port := range ports {
+		idx := slices.IndexFunc(groups, func(group calicoProtocolPorts) bool { return group.protocol == string(*port.Protocol) })
+		if idx < 0 {
+			groups = append(groups, calicoProtocolPorts{protocol: string(*port.Protocol)})
+			idx = len(groups) - 1
+		}
+		groups[idx].ports = append(groups[idx].ports, *port.Port)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, calicoProtocolPorts{})
+	}
+	return groups
+}
+
+// calicoSelector converts labels into a Calico selector expression, e.g., "app == 'shop' && tier == 'web'"
+func calicoSelector(labels map[string]string) string {
+	if len(labels) == 0 {
+		return calicoSelectAll
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	terms := make([]string, 0, len(keys))
+	for _, key := range keys {
+		terms = append(terms, fmt.Sprintf("%s == '%s'", key, labels[key]))
+	}
+	return strings.Join(terms, " && ")
+}
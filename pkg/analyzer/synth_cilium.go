@@ -0,0 +1,218 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	network "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	ciliumAPIVersion          = "cilium.io/v2"
+	ciliumNetworkPolicyKind   = "CiliumNetworkPolicy"
+	ciliumNamespaceLabel      = "k8s:io.kubernetes.pod.namespace"
+	ciliumEntityAll           = "all"
+	ciliumEntityCluster       = "cluster"
+	ciliumAnyProtocol         = "ANY"
+	ciliumDNSMatchAllPattern  = "*"
+	ciliumK8sLabelPrefix      = "k8s:"
+	ciliumDefaultDenyNameBase = "default-deny-in-namespace"
+)
+
+// The types below are a minimal subset of the CiliumNetworkPolicy API (cilium.io/v2)
+
+type ciliumNetworkPolicy struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              ciliumRule `json:"spec"`
+}
+
+type ciliumRule struct {
+	EndpointSelector metaV1.LabelSelector `json:"endpointSelector"`
+	Ingress          []ciliumIngressRule  `json:"ingress,omitempty"`
+	Egress           []ciliumEgressRule   `json:"egress,omitempty"`
+}
+
+type ciliumIngressRule struct {
+	FromEndpoints []metaV1.LabelSelector `json:"fromEndpoints,omitempty"`
+	FromCIDR      []string               `json:"fromCIDR,omitempty"`
+	FromEntities  []string               `json:"fromEntities,omitempty"`
+	ToPorts       []ciliumPortRule       `json:"toPorts,omitempty"`
+}
+
+type ciliumEgressRule struct {
+	ToEndpoints []metaV1.LabelSelector `json:"toEndpoints,omitempty"`
+	ToCIDR      []string               `json:"toCIDR,omitempty"`
+	ToEntities  []string               `json:"toEntities,omitempty"`
+	ToFQDNs     []ciliumFQDNSelector   `json:"toFQDNs,omitempty"`
+	ToPorts     []ciliumPortRule       `json:"toPorts,omitempty"`
+}
+
+type ciliumPortRule struct {
+	Ports []ciliumPortProtocol `json:"ports"`
+	Rules *ciliumL7Rules       `json:"rules,omitempty"`
+}
+
+type ciliumPortProtocol struct {
+	Port     string `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type ciliumL7Rules struct {
+	DNS []ciliumFQDNSelector `json:"dns,omitempty"`
+}
+
+type ciliumFQDNSelector struct {
+	MatchName    string `json:"matchName,omitempty"`
+	MatchPattern string `json:"matchPattern,omitempty"`
+}
+
+// buildCiliumPolicies generates a CiliumNetworkPolicy for each workload, allowing its discovered connections,
+// and a default-deny CiliumNetworkPolicy for each workload namespace.
+// Egress to external hosts is allowed using toFQDNs rules. As these require Cilium's DNS proxy to see the DNS lookups,
+// egress to the cluster DNS pods is allowed using a DNS-aware rule.
+func (ps *PoliciesSynthesizer) buildCiliumPolicies(resources []*Resource,
+	deployConnectivity []*deploymentConnectivity) []*unstructured.Unstructured {
+	policyNames := netpolNamesPerDeployment(deployConnectivity)
+	cnps := []*ciliumNetworkPolicy{}
+	for _, deployConn := range deployConnectivity {
+		cnp := newCiliumNetworkPolicy(policyNames[deployConn], deployConn.Resource.Resource.Namespace)
+		cnp.Spec.EndpointSelector = *getDeployConnSelector(deployConn)
+		for _, rule := range deployConn.ingressConns {
+			ports := toCiliumPorts(rule.Ports)
+			if len(rule.From) == 0 {
+				cnp.Spec.Ingress = append(cnp.Spec.Ingress, ciliumIngressRule{FromEntities: []string{ciliumEntityAll}, ToPorts: ports})
+			}
+			for i := range rule.From {
+				endpoints, cidrs, entities := toCiliumPeer(&rule.From[i])
+				cnp.Spec.Ingress = append(cnp.Spec.Ingress,
+					ciliumIngressRule{FromEndpoints: endpoints, FromCIDR: cidrs, FromEntities: entities, ToPorts: ports})
+			}
+		}
+		for _, rule := range deployConn.egressConns {
+			ports := toCiliumPorts(rule.Ports)
+			for i := range rule.To {
+				endpoints, cidrs, entities := toCiliumPeer(&rule.To[i])
+				cnp.Spec.Egress = append(cnp.Spec.Egress,
+					ciliumEgressRule{ToEndpoints: endpoints, ToCIDR: cidrs, ToEntities: entities, ToPorts: ports})
+			}
+		}
+		for _, host := range deployConn.externalHosts {
+			rule := ciliumEgressRule{ToFQDNs: []ciliumFQDNSelector{{MatchName: host.Host}}}
+			if host.Port > 0 {
+				rule.ToPorts = toCiliumPorts(toNetpolPorts([]SvcNetworkAttr{{Port: host.Port}}, false, nil))
+			}
+			cnp.Spec.Egress = append(cnp.Spec.Egress, rule)
+		}
+		if len(cnp.Spec.Egress) > 0 {
+			cnp.Spec.Egress = append(cnp.Spec.Egress, ps.ciliumDNSEgressRules()...)
+		}
+		cnps = append(cnps, cnp)
+	}
+
+	for _, namespace := range workloadNamespaces(resources) {
+		policyName := ciliumDefaultDenyNameBase
+		if namespace != "" {
+			policyName += "-" + namespace
+		}
+		cnp := newCiliumNetworkPolicy(policyName, namespace)
+		// an empty rule selects all endpoints in the namespace for enforcement, without allowing anything
+		cnp.Spec.Ingress = []ciliumIngressRule{{}}
+		cnp.Spec.Egress = []ciliumEgressRule{{}}
+		cnps = append(cnps, cnp)
+	}
+
+	return toUnstructuredObjects(cnps, ps.logger)
+}
+
+func newCiliumNetworkPolicy(name, namespace string) *ciliumNetworkPolicy {
+	return &ciliumNetworkPolicy{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       ciliumNetworkPolicyKind,
+			APIVersion: ciliumAPIVersion,
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// ciliumDNSEgressRules allow DNS lookups via the cluster DNS pods, letting Cilium's DNS proxy learn the IPs of external hosts.
+// Unless other DNS pods are set, the kube-dns pods are used, as a DNS-aware rule must select specific endpoints.
+func (ps *PoliciesSynthesizer) ciliumDNSEgressRules() []ciliumEgressRule {
+	namespace, podLabels := ps.dnsNamespace, ps.dnsPodSelector
+	if podLabels == nil {
+		namespace, podLabels = kubeDNSNamespace, map[string]string{kubeDNSLabelKey: kubeDNSLabelValue}
+	}
+	dnsPods := metaV1.LabelSelector{MatchLabels: map[string]string{ciliumNamespaceLabel: namespace}}
+	for key, value := range podLabels {
+		dnsPods.MatchLabels[ciliumK8sLabelPrefix+key] = value
+	}
+	dnsPorts := []ciliumPortRule{{
+		Ports: []ciliumPortProtocol{{Port: ps.dnsPort.String(), Protocol: ciliumAnyProtocol}},
+		Rules: &ciliumL7Rules{DNS: []ciliumFQDNSelector{{MatchPattern: ciliumDNSMatchAllPattern}}},
+	}}
+	rules := []ciliumEgressRule{{ToEndpoints: []metaV1.LabelSelector{dnsPods}, ToPorts: dnsPorts}}
+	if ps.nodeLocalDNSCIDR != "" {
+		rules = append(rules, ciliumEgressRule{ToCIDR: []string{ps.nodeLocalDNSCIDR}, ToPorts: dnsPorts})
+	}
+	return rules
+}
+
+// toCiliumPeer converts a NetworkPolicy peer into Cilium endpoint selectors, CIDRs or entities (exactly one of them is returned)
+func toCiliumPeer(peer *network.NetworkPolicyPeer) ([]metaV1.LabelSelector, []string, []string) {
+	switch {
+	case peer.IPBlock != nil:
+		return nil, []string{peer.IPBlock.CIDR}, nil
+	case peer.PodSelector == nil: // all pods in the cluster (generated peers with no pod selector have an empty namespace selector)
+		return nil, nil, []string{ciliumEntityCluster}
+	}
+
+	selector := metaV1.LabelSelector{MatchLabels: map[string]string{}}
+	for key, value := range peer.PodSelector.MatchLabels {
+		selector.MatchLabels[key] = value
+	}
+	if peer.NamespaceSelector == nil {
+		return []metaV1.LabelSelector{selector}, nil, nil // pods in the namespace of the policy (added by Cilium)
+	}
+	if namespace, ok := peer.NamespaceSelector.MatchLabels[namespaceNameLabel]; ok { // pods in another namespace
+		selector.MatchLabels[ciliumNamespaceLabel] = namespace
+	} else { // an empty namespace selector (e.g., the pods of an Istio Gateway) - pods in any namespace
+		selector.MatchExpressions = []metaV1.LabelSelectorRequirement{
+			{Key: ciliumNamespaceLabel, Operator: metaV1.LabelSelectorOpExists},
+		}
+	}
+	return []metaV1.LabelSelector{selector}, nil, nil
+}
+
+func toCiliumPorts(ports []network.NetworkPolicyPort) []ciliumPortRule {
+	if len(ports) == 0 {
+		return nil
+	}
+	ciliumPorts := make([]ciliumPortProtocol, 0, len(ports))
+	for _, port := range ports {
+		ciliumPorts = append(ciliumPorts, ciliumPortProtocol{Port: port.Port.String(), Protocol: string(*port.Protocol)})
+	}
+	return []ciliumPortRule{{Ports: ciliumPorts}}
+}
+
+// toUnstructuredObjects converts policy objects into Unstructured objects
+func toUnstructuredObjects[T any](policies []*T, logger Logger) []*unstructured.Unstructured {
+	objects := make([]*unstructured.Unstructured, 0, len(policies))
+	for _, policy := range policies {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(policy)
+		if err != nil {
+			logger.Errorf(err, "failed converting a policy")
+			continue
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: content})
+	}
+	return objects
+}
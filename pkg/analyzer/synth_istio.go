@@ -0,0 +1,185 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	network "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	istioAPIVersion             = "security.istio.io/v1"
+	istioAuthorizationKind      = "AuthorizationPolicy"
+	istioAllowNothingPolicyName = "allow-nothing"
+	istioTrustDomain            = "cluster.local" // Istio's default trust domain
+	defaultServiceAccount       = "default"
+)
+
+// The types below are a minimal subset of Istio's AuthorizationPolicy API (security.istio.io/v1)
+
+type istioAuthorizationPolicy struct {
+	metaV1.TypeMeta   `json:",inline"`
+	metaV1.ObjectMeta `json:"metadata"`
+	Spec              istioAuthorizationSpec `json:"spec"`
+}
+
+type istioAuthorizationSpec struct {
+	Selector *istioWorkloadSelector `json:"selector,omitempty"`
+	Action   string                 `json:"action,omitempty"`
+	Rules    []istioRule            `json:"rules,omitempty"`
+}
+
+type istioWorkloadSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+type istioRule struct {
+	From []istioRuleFrom `json:"from,omitempty"` // no sources means any source
+	To   []istioRuleTo   `json:"to,omitempty"`   // no operations means any port
+}
+
+type istioRuleFrom struct {
+	Source istioSource `json:"source"`
+}
+
+type istioSource struct {
+	Principals []string `json:"principals"`
+}
+
+type istioRuleTo struct {
+	Operation istioOperation `json:"operation"`
+}
+
+type istioOperation struct {
+	Ports []string `json:"ports"`
+}
+
+// buildIstioAuthorizationPolicies generates an AuthorizationPolicy for each target workload, allowing calls only from
+// the service-account principals of its discovered sources, on the discovered ports. Exposed workloads may be called
+// from any source on their exposed ports. In addition, an "allow-nothing" AuthorizationPolicy is generated for each
+// workload namespace, so workloads with no discovered sources cannot be called at all.
+func (ps *PoliciesSynthesizer) buildIstioAuthorizationPolicies(resources []*Resource,
+	connections []*Connections) []*unstructured.Unstructured {
+	deployConns := map[string]*deploymentConnectivity{}
+	rules := map[*deploymentConnectivity][]istioRule{}
+	for _, conn := range connections {
+		if conn.Target == nil {
+			continue
+		}
+		hasSourceWorkload := conn.Source != nil && conn.Source.Resource.Name != ""
+		ports := connectionTargetPorts(conn, hasSourceWorkload)
+		if len(ports) == 0 {
+			continue
+		}
+		rule := istioRule{}
+		if portNums := ps.istioPorts(ports, conn.Target); len(portNums) > 0 {
+			rule.To = []istioRuleTo{{Operation: istioOperation{Ports: portNums}}}
+		}
+		if hasSourceWorkload && !conn.Link.Resource.ExposeExternally {
+			rule.From = []istioRuleFrom{{Source: istioSource{Principals: []string{istioPrincipal(conn.Source, conn.Target)}}}}
+		}
+		target := findOrAddDeploymentConn(conn.Target, deployConns)
+		rules[target] = addIstioRule(rules[target], &rule)
+	}
+	for _, res := range resources { // ports scraped by PodMonitors may be called from any source
+		if len(res.Resource.scrapedPorts) == 0 {
+			continue
+		}
+		rule := istioRule{}
+		if portNums := ps.istioPorts(toNetpolPorts(res.Resource.scrapedPorts, false, res), res); len(portNums) > 0 {
+			rule.To = []istioRuleTo{{Operation: istioOperation{Ports: portNums}}}
+		}
+		target := findOrAddDeploymentConn(res, deployConns)
+		rules[target] = addIstioRule(rules[target], &rule)
+	}
+
+	targets := sortedDeploymentConns(deployConns)
+	policyNames := netpolNamesPerDeployment(targets)
+	policies := []*istioAuthorizationPolicy{}
+	for _, target := range targets {
+		policyName := strings.TrimSuffix(policyNames[target], "-netpol") + "-authz"
+		policy := newIstioAuthorizationPolicy(policyName, target.Resource.Resource.Namespace)
+		policy.Spec.Selector = &istioWorkloadSelector{MatchLabels: target.Resource.Resource.Labels}
+		policy.Spec.Action = "ALLOW"
+		policy.Spec.Rules = rules[target]
+		policies = append(policies, policy)
+	}
+	for _, namespace := range workloadNamespaces(resources) {
+		// an ALLOW policy with no rules denies all calls to the workloads in the namespace, unless allowed by another policy
+		policies = append(policies, newIstioAuthorizationPolicy(istioAllowNothingPolicyName, namespace))
+	}
+	return toUnstructuredObjects(policies, ps.logger)
+}
+
+// istioPorts converts the given NetworkPolicy ports into AuthorizationPolicy ports, which can only be port numbers.
+// If some port is named (i.e., it could not be resolved to a port number), no ports are returned, so any port is allowed.
+func (ps *PoliciesSynthesizer) istioPorts(ports []network.NetworkPolicyPort, target *Resource) []string {
+	portNums := make([]string, 0, len(ports))
+	for _, port := range ports {
+		if port.Port.Type == intstr.String {
+			ps.logger.Warnf("calls to %s on named port %s will be allowed on any port, as AuthorizationPolicies require port numbers",
+				target.Resource.Name, port.Port.StrVal)
+			return nil
+		}
+		portNums = append(portNums, port.Port.String())
+	}
+	return portNums
+}
+
+func newIstioAuthorizationPolicy(name, namespace string) *istioAuthorizationPolicy {
+	return &istioAuthorizationPolicy{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       istioAuthorizationKind,
+			APIVersion: istioAPIVersion,
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// istioPrincipal returns the principal of the given source workload, based on its service account.
+// A source with no namespace is assumed to be in the target's namespace.
+func istioPrincipal(source, target *Resource) string {
+	namespace := source.Resource.Namespace
+	if namespace == "" {
+		namespace = target.Resource.Namespace
+	}
+	serviceAccount := source.Resource.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = defaultServiceAccount
+	}
+	return fmt.Sprintf("%s/ns/%s/sa/%s", istioTrustDomain, namespaceOrDefault(namespace), serviceAccount)
+}
+
+// addIstioRule adds the given rule to the given rules. If a rule with the same ports exists, the sources are merged into it.
+func addIstioRule(rules []istioRule, rule *istioRule) []istioRule {
+	idx := slices.IndexFunc(rules, func(existingRule istioRule) bool { return reflect.DeepEqual(existingRule.To, rule.To) })
+	if idx < 0 {
+		return append(rules, *rule)
+	}
+	existingRule := &rules[idx]
+	if len(existingRule.From) == 0 || len(rule.From) == 0 { // any source is allowed
+		existingRule.From = nil
+		return rules
+	}
+	principals := &existingRule.From[0].Source.Principals
+	for _, principal := range rule.From[0].Source.Principals {
+		if !slices.Contains(*principals, principal) {
+			*principals = append(*principals, principal)
+		}
+	}
+	return rules
+}
@@ -7,8 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package analyzer
 
 import (
+	"fmt"
 	"reflect"
+	"slices"
 	"sort"
+	"strings"
 
 	core "k8s.io/api/core/v1"
 	network "k8s.io/api/networking/v1"
@@ -19,12 +22,48 @@ import (
 const (
 	networkAPIVersion = "networking.k8s.io/v1"
 	networkPolicyKind = "NetworkPolicy"
+
+	anyIPv4 = "0.0.0.0/0"
+	anyIPv6 = "::/0"
+
+	kubeDNSNamespace       = "kube-system"
+	kubeDNSLabelKey        = "k8s-app"
+	kubeDNSLabelValue      = "kube-dns"
+	openShiftDNSNamespace  = "openshift-dns"
+	openShiftDNSLabelKey   = "dns.operator.openshift.io/daemonset-dns"
+	openShiftDNSLabelValue = "default"
+	openShiftDNSPort       = 5353 // the OpenShift DNS pods listen on this port (the DNS Service maps port 53 to it)
+
+	gatewayNameLabel = "gateway.networking.k8s.io/gateway-name"
+
+	prometheusLabelKey           = "app.kubernetes.io/name"
+	prometheusLabelValue         = "prometheus"
+	kubePrometheusNamespace      = "monitoring"
+	openShiftMonitoringNamespace = "openshift-monitoring"
 )
 
+// namespacedPods are the pods in a namespace which match a label selector
+type namespacedPods struct {
+	namespace   string
+	podSelector map[string]string
+}
+
+var ingressControllerPods = map[IngressController]namespacedPods{
+	IngressNginxController: {"ingress-nginx",
+		map[string]string{"app.kubernetes.io/name": "ingress-nginx", "app.kubernetes.io/component": "controller"}},
+	OpenShiftRouter: {"openshift-ingress",
+		map[string]string{"ingresscontroller.operator.openshift.io/deployment-ingresscontroller": "default"}},
+	ContourController: {"projectcontour", map[string]string{"app": "envoy"}},
+	EnvoyGatewayController: {"envoy-gateway-system",
+		map[string]string{"app.kubernetes.io/managed-by": "envoy-gateway", "app.kubernetes.io/component": "proxy"}},
+	IstioIngressGateway: {"istio-system", map[string]string{"istio": "ingressgateway"}},
+}
+
 type deploymentConnectivity struct {
 	Resource
-	ingressConns []network.NetworkPolicyIngressRule
-	egressConns  []network.NetworkPolicyEgressRule
+	ingressConns  []network.NetworkPolicyIngressRule
+	egressConns   []network.NetworkPolicyEgressRule
+	externalHosts []ExternalEndpoint // egress to external host names (only for policy formats which can refer to host names)
 }
 
 func (deployConn *deploymentConnectivity) addIngressRule(
@@ -73,36 +112,81 @@ func getNsDefaultDenyPolicy(namespace string) *network.NetworkPolicy {
 	}
 }
 
-// Generate default-deny NetworkPolicy for each namespace of the given resources
+// Generate default-deny NetworkPolicy for each namespace of the given resources (sorted by namespace)
 func getNsDefaultDenyPolicies(resources []*Resource) []*network.NetworkPolicy {
-	denyNetpols := []*network.NetworkPolicy{}
-	namespaces := map[string]bool{}
+	namespaces := workloadNamespaces(resources)
+	denyNetpols := make([]*network.NetworkPolicy, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		denyNetpols = append(denyNetpols, getNsDefaultDenyPolicy(namespace))
+	}
+	return denyNetpols
+}
+
+// workloadNamespaces returns the (sorted) namespaces of the given resources
+func workloadNamespaces(resources []*Resource) []string {
+	namespaces := []string{}
 	for _, res := range resources {
-		namespace := res.Resource.Namespace
-		if _, ok := namespaces[namespace]; !ok {
-			namespaces[namespace] = true
-			denyNetpols = append(denyNetpols, getNsDefaultDenyPolicy(namespace))
+		if !slices.Contains(namespaces, res.Resource.Namespace) {
+			namespaces = append(namespaces, res.Resource.Namespace)
 		}
 	}
-	return denyNetpols
+	slices.Sort(namespaces)
+	return namespaces
+}
+
+// synthNetpols generates the policies, returning the NetworkPolicies and warnings about requested policies which are not generated
+func (ps *PoliciesSynthesizer) synthNetpols(resources []*Resource, connections []*Connections) (
+	[]*network.NetworkPolicy, []FileProcessingError) {
+	ps.policyObjects, ps.banp, ps.anps = nil, nil, nil
+	warnings := []FileProcessingError{}
+	if ps.policyFormat != K8sPolicyFormat && (ps.baselineAdminPolicy || ps.crossNsAdminPolicies) {
+		warnings = appendAndLogNewError(warnings, adminPoliciesNotSupported(ps.policyFormat), ps.logger)
+	}
+	netpols := ps.synthPoliciesInFormat(resources, connections)
+	if ps.istioAuthzPolicies {
+		ps.policyObjects = append(ps.policyObjects, ps.buildIstioAuthorizationPolicies(resources, connections)...)
+	}
+	return netpols, warnings
 }
 
-func (ps *PoliciesSynthesizer) synthNetpols(resources []*Resource, connections []*Connections) []*network.NetworkPolicy {
-	deployConnectivity := determineConnectivityPerDeployment(connections)
+// synthPoliciesInFormat generates the policies in the policy format of the PoliciesSynthesizer.
+// Only NetworkPolicies are returned; policies in other formats and admin policies are stored in the PoliciesSynthesizer.
+func (ps *PoliciesSynthesizer) synthPoliciesInFormat(resources []*Resource, connections []*Connections) []*network.NetworkPolicy {
+	deployConnectivity := ps.determineConnectivityPerDeployment(resources, connections)
+	switch ps.policyFormat {
+	case CiliumPolicyFormat:
+		ps.policyObjects = ps.buildCiliumPolicies(resources, deployConnectivity)
+		return []*network.NetworkPolicy{}
+	case CalicoPolicyFormat:
+		ps.policyObjects = ps.buildCalicoPolicies(resources, deployConnectivity)
+		return []*network.NetworkPolicy{}
+	}
+
 	netpols := ps.buildNetpolPerDeployment(deployConnectivity)
-	netpols = append(netpols, getNsDefaultDenyPolicies(resources)...)
+	if ps.baselineAdminPolicy {
+		ps.banp = getBaselineAdminPolicy(resources)
+	} else {
+		netpols = append(netpols, getNsDefaultDenyPolicies(resources)...)
+	}
+	if ps.crossNsAdminPolicies {
+		ps.anps = ps.synthCrossNamespaceAdminPolicies(connections)
+	}
 	return netpols
 }
 
-func determineConnectivityPerDeployment(connections []*Connections) []*deploymentConnectivity {
+// determineConnectivityPerDeployment returns the ingress and egress rules of each workload, allowing the given connections.
+// Ingress is also allowed to the ports of the given resources which are scraped by PodMonitors.
+func (ps *PoliciesSynthesizer) determineConnectivityPerDeployment(resources []*Resource,
+	connections []*Connections) []*deploymentConnectivity {
 	deploysConnectivity := map[string]*deploymentConnectivity{}
 	for _, conn := range connections {
+		if conn.ExternalTarget != nil {
+			ps.addExternalEgressRule(conn, deploysConnectivity)
+			continue
+		}
 		srcDeploy := findOrAddDeploymentConn(conn.Source, deploysConnectivity)
 		dstDeploy := findOrAddDeploymentConn(conn.Target, deploysConnectivity)
-		targetPorts := toNetpolPorts(conn.Link.Resource.Network, srcDeploy == nil && !conn.Link.Resource.ExposeExternally)
-		if conn.Source != nil && len(conn.Source.Resource.UsedPorts) > 0 {
-			targetPorts = toNetpolPorts(conn.Source.Resource.UsedPorts, false)
-		}
+		targetPorts := connectionTargetPorts(conn, srcDeploy != nil)
 		if len(targetPorts) == 0 {
 			continue
 		}
@@ -116,44 +200,175 @@ func determineConnectivityPerDeployment(connections []*Connections) []*deploymen
 		case conn.Link.Resource.ExposeExternally:
 			dstDeploy.addIngressRule([]network.NetworkPolicyPeer{}, targetPorts) // allowing traffic from all sources
 		case srcDeploy == nil:
-			peer := network.NetworkPolicyPeer{NamespaceSelector: &metaV1.LabelSelector{}}
-			dstDeploy.addIngressRule([]network.NetworkPolicyPeer{peer}, targetPorts) // allowing traffic from all cluster sources
+			for _, rule := range ps.exposureIngressRules(conn.Link.Resource.Network, conn.Target) {
+				dstDeploy.addIngressRule(rule.From, rule.Ports) // allowing traffic from the pods the ports are exposed to
+			}
 		default:
 			netpolPeer := getNetpolPeer(dstDeploy, srcDeploy)
 			dstDeploy.addIngressRule([]network.NetworkPolicyPeer{netpolPeer}, targetPorts) // allow traffic only from this specific source
 		}
 	}
+	for _, res := range resources {
+		if len(res.Resource.scrapedPorts) == 0 {
+			continue
+		}
+		dstDeploy := findOrAddDeploymentConn(res, deploysConnectivity)
+		for _, rule := range ps.exposureIngressRules(res.Resource.scrapedPorts, res) {
+			dstDeploy.addIngressRule(rule.From, rule.Ports) // allowing traffic from the Prometheus pods
+		}
+	}
+
+	return sortedDeploymentConns(deploysConnectivity)
+}
+
+// exposureIngressRules returns the ingress rules allowing a source-less connection to the target's ports exposed to the cluster.
+// Ports exposed by Ingress, Route, Gateway API route or Istio VirtualService resources may only be reached from the ingress
+// controller or Gateway pods, if these are known. Similarly, ports scraped by Prometheus may only be reached from the Prometheus pods,
+// if these are known. Other exposed ports may be reached from any pod in the cluster.
+func (ps *PoliciesSynthesizer) exposureIngressRules(exposedPorts []SvcNetworkAttr, target *Resource) []network.NetworkPolicyIngressRule {
+	rules := []network.NetworkPolicyIngressRule{}
+	for _, port := range exposedPorts {
+		if !port.exposeToCluster {
+			continue
+		}
+		peers := ps.exposurePeers(port.exposedBy)
+		ports := toNetpolPorts([]SvcNetworkAttr{port}, false, target)
+		idx := slices.IndexFunc(rules, func(rule network.NetworkPolicyIngressRule) bool { return reflect.DeepEqual(rule.From, peers) })
+		if idx < 0 {
+			rules = append(rules, network.NetworkPolicyIngressRule{From: peers, Ports: ports})
+		} else {
+			rules[idx].Ports = append(rules[idx].Ports, ports...)
+		}
+	}
+	return rules
+}
+
+// exposurePeers returns the peers which may reach a port exposed by the given exposers
+func (ps *PoliciesSynthesizer) exposurePeers(exposers []portExposer) []network.NetworkPolicyPeer {
+	allClusterPeers := []network.NetworkPolicyPeer{{NamespaceSelector: &metaV1.LabelSelector{}}}
+	peers := []network.NetworkPolicyPeer{}
+	for _, exposer := range exposers {
+		var peer network.NetworkPolicyPeer
+		switch {
+		case exposer.gateway != "" && ps.gatewayPeers:
+			namespace, name, _ := strings.Cut(exposer.gateway, "/")
+			peer = namespacedPodsPeer(namespace, map[string]string{gatewayNameLabel: name})
+		case exposer.istioGatewayPods != nil && len(exposer.istioGatewayPods.Spec.Selector) > 0 && ps.gatewayPeers:
+			peer = network.NetworkPolicyPeer{
+				NamespaceSelector: &metaV1.LabelSelector{}, // Istio Gateways select their pods in all namespaces
+				PodSelector:       &metaV1.LabelSelector{MatchLabels: exposer.istioGatewayPods.Spec.Selector},
+			}
+		case (exposer.ingressController || exposer.gateway != "" || exposer.istioGateway != "") && ps.ingressControllerPeer != nil:
+			peer = *ps.ingressControllerPeer
+		case exposer.prometheus && ps.prometheusPeer != nil:
+			peer = *ps.prometheusPeer
+		default:
+			return allClusterPeers
+		}
+		if !slices.ContainsFunc(peers, func(existingPeer network.NetworkPolicyPeer) bool { return reflect.DeepEqual(existingPeer, peer) }) {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return allClusterPeers
+	}
+	return peers
+}
+
+// namespacedPodsPeer returns a NetworkPolicy peer selecting the pods in the given namespace which match the given labels.
+// If no namespace is given, the pods are assumed to be in the namespace of the NetworkPolicy.
+func namespacedPodsPeer(namespace string, podSelector map[string]string) network.NetworkPolicyPeer {
+	peer := network.NetworkPolicyPeer{PodSelector: &metaV1.LabelSelector{MatchLabels: podSelector}}
+	if namespace != "" {
+		peer.NamespaceSelector = &metaV1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: namespace}}
+	}
+	return peer
+}
+
+// connectionTargetPorts returns the target ports used by the given connection. Unless the connection has a source workload,
+// only the ports exposed to the cluster are used (all ports, if the service is exposed externally).
+func connectionTargetPorts(conn *Connections, hasSourceWorkload bool) []network.NetworkPolicyPort {
+	if conn.Source != nil && len(conn.Source.Resource.UsedPorts) > 0 {
+		return toNetpolPorts(conn.Source.Resource.UsedPorts, false, conn.Target)
+	}
+	return toNetpolPorts(conn.Link.Resource.Network, !hasSourceWorkload && !conn.Link.Resource.ExposeExternally, conn.Target)
+}
 
+// sortedDeploymentConns returns the values of the given map, sorted by namespace, then by name (and kind, for same-named workloads)
+func sortedDeploymentConns(deploysConnectivity map[string]*deploymentConnectivity) []*deploymentConnectivity {
 	retSlice := make([]*deploymentConnectivity, 0, len(deploysConnectivity))
 	for _, deployConn := range deploysConnectivity {
 		retSlice = append(retSlice, deployConn)
 	}
-	// sort by name
 	sort.Slice(retSlice, func(i, j int) bool {
-		return retSlice[i].Resource.Resource.Name < retSlice[j].Resource.Resource.Name
+		res1, res2 := &retSlice[i].Resource.Resource, &retSlice[j].Resource.Resource
+		if res1.Namespace != res2.Namespace {
+			return res1.Namespace < res2.Namespace
+		}
+		if res1.Name != res2.Name {
+			return res1.Name < res2.Name
+		}
+		return res1.Kind < res2.Kind
 	})
 	return retSlice
 }
 
+// addExternalEgressRule allows egress from the source of the given "external egress" connection to its external target.
+// IP addresses and CIDRs are allowed using an ipBlock peer. As NetworkPolicies cannot refer to host names,
+// egress to an external host is only allowed if the user opted in, by allowing egress to any address on the target port.
+// Cilium policies can refer to host names, so egress to external hosts is recorded as is.
+func (ps *PoliciesSynthesizer) addExternalEgressRule(conn *Connections, deployConns map[string]*deploymentConnectivity) {
+	endpoint := conn.ExternalTarget
+	var peers []network.NetworkPolicyPeer
+	switch {
+	case endpoint.IPBlock != "":
+		peers = []network.NetworkPolicyPeer{{IPBlock: &network.IPBlock{CIDR: endpoint.IPBlock}}}
+	case ps.policyFormat == CiliumPolicyFormat:
+		deployConn := findOrAddDeploymentConn(conn.Source, deployConns)
+		if !slices.Contains(deployConn.externalHosts, *endpoint) {
+			deployConn.externalHosts = append(deployConn.externalHosts, *endpoint)
+		}
+		return
+	case ps.allowExternalEgress:
+		peers = []network.NetworkPolicyPeer{{IPBlock: &network.IPBlock{CIDR: anyIPv4}}, {IPBlock: &network.IPBlock{CIDR: anyIPv6}}}
+	default:
+		ps.logger.Warnf("egress from %s to external host %s:%d will be blocked by the generated policies",
+			conn.Source.Resource.Name, endpoint.Host, endpoint.Port)
+		return
+	}
+
+	var ports []network.NetworkPolicyPort // no ports means all ports
+	if endpoint.Port > 0 {
+		ports = toNetpolPorts([]SvcNetworkAttr{{Port: endpoint.Port}}, false, nil)
+	}
+	findOrAddDeploymentConn(conn.Source, deployConns).addEgressRule(peers, ports)
+}
+
 func findOrAddDeploymentConn(resource *Resource, deployConns map[string]*deploymentConnectivity) *deploymentConnectivity {
 	if resource == nil || resource.Resource.Name == "" {
 		return nil
 	}
-	if deployConn, found := deployConns[resource.Resource.Name]; found {
+	key := workloadKey(resource)
+	if deployConn, found := deployConns[key]; found {
 		return deployConn
 	}
 
 	deploy := deploymentConnectivity{Resource: *resource}
-	deployConns[resource.Resource.Name] = &deploy
+	deployConns[key] = &deploy
 	return &deploy
 }
 
+// workloadKey uniquely identifies a workload, in the form "<namespace>/<kind>/<name>"
+func workloadKey(resource *Resource) string {
+	return resource.Resource.Namespace + "/" + resource.Resource.Kind + "/" + resource.Resource.Name
+}
+
 func getNetpolPeer(netpolDeploy, otherDeploy *deploymentConnectivity) network.NetworkPolicyPeer {
 	netpolPeer := network.NetworkPolicyPeer{PodSelector: getDeployConnSelector(otherDeploy)}
 	if netpolDeploy.Resource.Resource.Namespace != otherDeploy.Resource.Resource.Namespace {
 		if otherDeploy.Resource.Resource.Namespace != "" {
 			netpolPeer.NamespaceSelector = &metaV1.LabelSelector{
-				MatchLabels: map[string]string{"kubernetes.io/metadata.name": otherDeploy.Resource.Resource.Namespace},
+				MatchLabels: map[string]string{namespaceNameLabel: otherDeploy.Resource.Resource.Namespace},
 			}
 		} // if otherDeploy has no namespace specified, we assume it is in the same namespace as the netpolDeploy
 	}
@@ -164,20 +379,24 @@ func getDeployConnSelector(deployConn *deploymentConnectivity) *metaV1.LabelSele
 	return &metaV1.LabelSelector{MatchLabels: deployConn.Resource.Resource.Labels}
 }
 
-func toNetpolPorts(ports []SvcNetworkAttr, exposedOnly bool) []network.NetworkPolicyPort {
+// toNetpolPorts converts service ports into NetworkPolicy ports. If a target workload is given, target ports are resolved
+// using the ports declared by its containers, so named ports are converted into port numbers.
+func toNetpolPorts(ports []SvcNetworkAttr, exposedOnly bool, target *Resource) []network.NetworkPolicyPort {
 	netpolPorts := make([]network.NetworkPolicyPort, 0, len(ports))
 	for _, port := range ports {
 		if exposedOnly && !port.exposeToCluster {
 			continue
 		}
-		protocol := port.Protocol
-		if protocol == "" {
-			protocol = core.ProtocolTCP
-		}
+		protocol := protocolOrDefault(port.Protocol)
 		portNum := port.TargetPort
 		if portNum.Type == intstr.Int && portNum.IntVal == 0 {
 			portNum = intstr.FromInt(port.Port)
 		}
+		if target != nil {
+			if declaredPort := target.declaredPort(&port); declaredPort != nil {
+				portNum = intstr.FromInt32(declaredPort.ContainerPort)
+			}
+		}
 		netpolPort := network.NetworkPolicyPort{
 			Protocol: &protocol,
 			Port:     &portNum,
@@ -189,18 +408,16 @@ func toNetpolPorts(ports []SvcNetworkAttr, exposedOnly bool) []network.NetworkPo
 
 func (ps *PoliciesSynthesizer) buildNetpolPerDeployment(deployConnectivity []*deploymentConnectivity) []*network.NetworkPolicy {
 	netpols := make([]*network.NetworkPolicy, 0, len(deployConnectivity))
+	netpolNames := netpolNamesPerDeployment(deployConnectivity)
 	for _, deployConn := range deployConnectivity {
-		if len(deployConn.egressConns) > 0 { // add a rule to allow egress DNS traffic (inside the cluster)
-			allClusterPeers := []network.NetworkPolicyPeer{{NamespaceSelector: &metaV1.LabelSelector{}}}
-			deployConn.addEgressRule(allClusterPeers, []network.NetworkPolicyPort{getDNSPort(&ps.dnsPort)})
-		}
+		ps.addDNSEgressRule(deployConn)
 		netpol := network.NetworkPolicy{
 			TypeMeta: metaV1.TypeMeta{
 				Kind:       networkPolicyKind,
 				APIVersion: networkAPIVersion,
 			},
 			ObjectMeta: metaV1.ObjectMeta{
-				Name:      deployConn.Resource.Resource.Name + "-netpol",
+				Name:      netpolNames[deployConn],
 				Namespace: deployConn.Resource.Resource.Namespace,
 			},
 			Spec: network.NetworkPolicySpec{
@@ -215,14 +432,88 @@ func (ps *PoliciesSynthesizer) buildNetpolPerDeployment(deployConnectivity []*de
 	return netpols
 }
 
-func getDNSPort(portNum *intstr.IntOrString) network.NetworkPolicyPort {
-	udp := core.ProtocolUDP
+// netpolNamesPerDeployment returns a unique name for the NetworkPolicy of each workload: "<workload-name>-netpol".
+// If workloads of different kinds share the same name and namespace, their kind is also added: "<name>-<kind>-netpol".
+// If such a name is already taken by another workload (e.g., a Deployment named "<name>-<kind>"), a counter is also added.
+// (NetworkPolicy names only have to be unique within a namespace.)
+func netpolNamesPerDeployment(deployConnectivity []*deploymentConnectivity) map[*deploymentConnectivity]string {
+	workloadsPerName := map[string]int{}
+	for _, deployConn := range deployConnectivity {
+		workloadsPerName[deployConn.Resource.Resource.Namespace+"/"+deployConn.Resource.Resource.Name]++
+	}
+	names := make(map[*deploymentConnectivity]string, len(deployConnectivity))
+	usedNames := map[string]bool{}
+	for _, deployConn := range deployConnectivity { // plain names first, so they do not depend on other workloads
+		res := &deployConn.Resource.Resource
+		if workloadsPerName[res.Namespace+"/"+res.Name] == 1 {
+			names[deployConn] = res.Name + "-netpol"
+			usedNames[res.Namespace+"/"+names[deployConn]] = true
+		}
+	}
+	for _, deployConn := range deployConnectivity {
+		res := &deployConn.Resource.Resource
+		if workloadsPerName[res.Namespace+"/"+res.Name] == 1 {
+			continue
+		}
+		prefix := res.Name + "-" + strings.ToLower(res.Kind)
+		name := prefix + "-netpol"
+		for i := 2; usedNames[res.Namespace+"/"+name]; i++ {
+			name = fmt.Sprintf("%s-%d-netpol", prefix, i)
+		}
+		names[deployConn] = name
+		usedNames[res.Namespace+"/"+name] = true
+	}
+	return names
+}
+
+// addDNSEgressRule adds a rule to allow egress DNS traffic (inside the cluster), if the workload has any egress
+func (ps *PoliciesSynthesizer) addDNSEgressRule(deployConn *deploymentConnectivity) {
+	if len(deployConn.egressConns) > 0 {
+		deployConn.addEgressRule(ps.dnsPeers(), ps.dnsPorts())
+	}
+}
+
+// dnsPeers returns the peers to allow DNS egress to: the DNS pods if set (any pod in the cluster otherwise),
+// and the NodeLocal DNSCache address if set
+func (ps *PoliciesSynthesizer) dnsPeers() []network.NetworkPolicyPeer {
+	peers := []network.NetworkPolicyPeer{{NamespaceSelector: &metaV1.LabelSelector{}}}
+	if ps.dnsPodSelector != nil {
+		peers = []network.NetworkPolicyPeer{namespacedPodsPeer(ps.dnsNamespace, ps.dnsPodSelector)}
+	}
+	if ps.nodeLocalDNSCIDR != "" {
+		peers = append(peers, network.NetworkPolicyPeer{IPBlock: &network.IPBlock{CIDR: ps.nodeLocalDNSCIDR}})
+	}
+	return peers
+}
+
+// dnsPorts returns the DNS port over UDP, and also over TCP if DNS over TCP is allowed
+func (ps *PoliciesSynthesizer) dnsPorts() []network.NetworkPolicyPort {
+	ports := []network.NetworkPolicyPort{getDNSPort(&ps.dnsPort, core.ProtocolUDP)}
+	if ps.dnsOverTCP {
+		ports = append(ports, getDNSPort(&ps.dnsPort, core.ProtocolTCP))
+	}
+	return ports
+}
+
+func getDNSPort(portNum *intstr.IntOrString, protocol core.Protocol) network.NetworkPolicyPort {
 	return network.NetworkPolicyPort{
-		Protocol: &udp,
+		Protocol: &protocol,
 		Port:     portNum,
 	}
 }
 
+// hostCIDR returns the CIDR of the given IP address alone (the given string is returned if it is already a CIDR)
+func hostCIDR(address string) string {
+	switch {
+	case strings.Contains(address, "/"):
+		return address
+	case strings.Contains(address, ":"):
+		return address + "/128"
+	default:
+		return address + "/32"
+	}
+}
+
 // NetpolListFromNetpolSlice converts a slice of Kubernetes NetworkPolicies to a Kubernetes NetworkPolicyList
 // containing all the policies in the slice.
 func NetpolListFromNetpolSlice(netpols []*network.NetworkPolicy) network.NetworkPolicyList {
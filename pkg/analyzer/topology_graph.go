@@ -0,0 +1,286 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	exposureNodeLabel  = "external/ingress" // the source of connections to services exposed by Ingress, Routes, LoadBalancers, etc.
+	displayedDefaultNs = "default"          // resources with no namespace are drawn in the default namespace
+	nodeIDPrefix       = "n"
+)
+
+// graphNode is a node in the topology graph: a workload, an external endpoint or the external/ingress node
+type graphNode struct {
+	id    string
+	idx   int // the node's position in the drawing order
+	label string
+}
+
+// graphEdge is a (directed) edge in the topology graph
+type graphEdge struct {
+	from, to *graphNode
+	label    string
+	exposure bool // an edge from the external/ingress node
+}
+
+// topologyGraph is a format-independent representation of the discovered connections, used for drawing them
+type topologyGraph struct {
+	nsNodes       map[string]map[string]*graphNode // for each namespace, its workload nodes, keyed by label
+	externalNodes map[string]*graphNode            // external endpoints and the external/ingress node, keyed by label
+	edges         []*graphEdge
+}
+
+// ConnectionsToDOT renders the given connections as a Graphviz DOT digraph.
+// Workloads are drawn as nodes, grouped into a cluster per namespace. Each edge is labelled with the service name and ports.
+// Services with no sources, which are exposed (e.g., by an Ingress), get an edge from a distinct "external/ingress" node.
+func ConnectionsToDOT(connections []*Connections) string {
+	graph := newTopologyGraph(connections)
+	var sb strings.Builder
+	sb.WriteString("digraph topology {\n")
+	sb.WriteString("\trankdir=LR\n")
+	sb.WriteString("\tnode [shape=box]\n")
+	for _, ns := range graph.namespaces() {
+		fmt.Fprintf(&sb, "\tsubgraph %s {\n", dotQuote("cluster_"+ns))
+		fmt.Fprintf(&sb, "\t\tlabel=%s\n", dotQuote(ns))
+		for _, node := range graph.nodesInNamespace(ns) {
+			fmt.Fprintf(&sb, "\t\t%s [label=%s]\n", node.id, dotQuote(node.label))
+		}
+		sb.WriteString("\t}\n")
+	}
+	for _, node := range graph.sortedExternalNodes() {
+		fmt.Fprintf(&sb, "\t%s [label=%s, shape=ellipse, style=dashed]\n", node.id, dotQuote(node.label))
+	}
+	for _, edge := range graph.edges {
+		attrs := "label=" + dotQuote(edge.label)
+		if edge.exposure {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s [%s]\n", edge.from.id, edge.to.id, attrs)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// ConnectionsToMermaid renders the given connections as a Mermaid flowchart.
+// The graph's layout is the same as in ConnectionsToDOT(), with a subgraph per namespace.
+func ConnectionsToMermaid(connections []*Connections) string {
+	graph := newTopologyGraph(connections)
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for idx, ns := range graph.namespaces() {
+		fmt.Fprintf(&sb, "\tsubgraph ns%d [%s]\n", idx, mermaidQuote(ns))
+		for _, node := range graph.nodesInNamespace(ns) {
+			fmt.Fprintf(&sb, "\t\t%s[%s]\n", node.id, mermaidQuote(node.label))
+		}
+		sb.WriteString("\tend\n")
+	}
+	for _, node := range graph.sortedExternalNodes() {
+		fmt.Fprintf(&sb, "\t%s([%s])\n", node.id, mermaidQuote(node.label))
+	}
+	for _, edge := range graph.edges {
+		arrow := "-->"
+		if edge.exposure {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "\t%s %s|%s| %s\n", edge.from.id, arrow, mermaidQuote(edge.label), edge.to.id)
+	}
+	return sb.String()
+}
+
+func newTopologyGraph(connections []*Connections) *topologyGraph {
+	graph := topologyGraph{nsNodes: map[string]map[string]*graphNode{}, externalNodes: map[string]*graphNode{}}
+	for _, conn := range connections {
+		switch {
+		case conn.ExternalTarget != nil:
+			src := graph.workloadNode(conn.Source)
+			graph.addEdge(src, graph.externalNode(externalEndpointLabel(conn.ExternalTarget)), externalPortLabel(conn.ExternalTarget), false)
+		case conn.Source != nil:
+			ports := conn.Link.Resource.Network
+			if len(conn.Source.Resource.UsedPorts) > 0 {
+				ports = conn.Source.Resource.UsedPorts
+			}
+			graph.addEdge(graph.workloadNode(conn.Source), graph.workloadNode(conn.Target), edgeLabel(conn.Link, ports), false)
+		default: // a source-less service
+			target := graph.workloadNode(conn.Target)
+			if exposedPorts := exposedServicePorts(conn.Link); len(exposedPorts) > 0 {
+				graph.addEdge(graph.externalNode(exposureNodeLabel), target, edgeLabel(conn.Link, exposedPorts), true)
+			}
+		}
+	}
+	graph.assignNodeIDs()
+	sort.SliceStable(graph.edges, func(i, j int) bool {
+		e1, e2 := graph.edges[i], graph.edges[j]
+		if e1.from != e2.from {
+			return e1.from.idx < e2.from.idx
+		}
+		if e1.to != e2.to {
+			return e1.to.idx < e2.to.idx
+		}
+		return e1.label < e2.label
+	})
+	return &graph
+}
+
+func (graph *topologyGraph) workloadNode(res *Resource) *graphNode {
+	ns := res.Resource.Namespace
+	if ns == "" {
+		ns = displayedDefaultNs
+	}
+	if graph.nsNodes[ns] == nil {
+		graph.nsNodes[ns] = map[string]*graphNode{}
+	}
+	label := fmt.Sprintf("%s\n(%s)", res.Resource.Name, res.Resource.Kind)
+	node, ok := graph.nsNodes[ns][label]
+	if !ok {
+		node = &graphNode{label: label}
+		graph.nsNodes[ns][label] = node
+	}
+	return node
+}
+
+func (graph *topologyGraph) externalNode(label string) *graphNode {
+	node, ok := graph.externalNodes[label]
+	if !ok {
+		node = &graphNode{label: label}
+		graph.externalNodes[label] = node
+	}
+	return node
+}
+
+// addEdge adds an edge to the graph, unless an identical edge already exists
+func (graph *topologyGraph) addEdge(from, to *graphNode, label string, exposure bool) {
+	edge := graphEdge{from: from, to: to, label: label, exposure: exposure}
+	for _, existingEdge := range graph.edges {
+		if *existingEdge == edge {
+			return
+		}
+	}
+	graph.edges = append(graph.edges, &edge)
+}
+
+func (graph *topologyGraph) namespaces() []string {
+	namespaces := make([]string, 0, len(graph.nsNodes))
+	for ns := range graph.nsNodes {
+		namespaces = append(namespaces, ns)
+	}
+	slices.Sort(namespaces)
+	return namespaces
+}
+
+// nodesInNamespace returns the workload nodes of the given namespace, sorted by name and then by kind
+func (graph *topologyGraph) nodesInNamespace(ns string) []*graphNode {
+	return sortedNodes(graph.nsNodes[ns])
+}
+
+func (graph *topologyGraph) sortedExternalNodes() []*graphNode {
+	return sortedNodes(graph.externalNodes)
+}
+
+// sortedNodes returns the nodes in the given map, sorted by their labels
+func sortedNodes(nodesByLabel map[string]*graphNode) []*graphNode {
+	labels := make([]string, 0, len(nodesByLabel))
+	for label := range nodesByLabel {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	nodes := make([]*graphNode, 0, len(labels))
+	for _, label := range labels {
+		nodes = append(nodes, nodesByLabel[label])
+	}
+	return nodes
+}
+
+// assignNodeIDs gives all nodes identifiers which are valid in all output formats, in the order in which they are drawn
+func (graph *topologyGraph) assignNodeIDs() {
+	nextIdx := 0
+	assign := func(node *graphNode) {
+		node.idx = nextIdx
+		node.id = nodeIDPrefix + strconv.Itoa(nextIdx)
+		nextIdx++
+	}
+	for _, ns := range graph.namespaces() {
+		for _, node := range graph.nodesInNamespace(ns) {
+			assign(node)
+		}
+	}
+	for _, node := range graph.sortedExternalNodes() {
+		assign(node)
+	}
+}
+
+// exposedServicePorts returns the ports of the given service which are exposed outside the cluster or to the whole cluster
+func exposedServicePorts(svc *Service) []SvcNetworkAttr {
+	if svc.Resource.ExposeExternally {
+		return svc.Resource.Network
+	}
+	ports := []SvcNetworkAttr{}
+	for _, port := range svc.Resource.Network {
+		if port.exposeToCluster {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// edgeLabel returns a label of the form "<service-name>: <port>[/<protocol>], ..." (the protocol is omitted for TCP ports)
+func edgeLabel(svc *Service, ports []SvcNetworkAttr) string {
+	portStrs := []string{}
+	for _, port := range ports {
+		portStr := strconv.Itoa(port.Port)
+		if port.Port == 0 {
+			portStr = port.TargetPort.String()
+		}
+		if protocol := protocolOrDefault(port.Protocol); protocol != corev1.ProtocolTCP {
+			portStr += "/" + string(protocol)
+		}
+		if !slices.Contains(portStrs, portStr) {
+			portStrs = append(portStrs, portStr)
+		}
+	}
+	if len(portStrs) == 0 {
+		return svc.Resource.Name
+	}
+	return svc.Resource.Name + ": " + strings.Join(portStrs, ", ")
+}
+
+func externalEndpointLabel(endpoint *ExternalEndpoint) string {
+	if endpoint.IPBlock != "" {
+		return endpoint.IPBlock
+	}
+	return endpoint.Host
+}
+
+func externalPortLabel(endpoint *ExternalEndpoint) string {
+	if endpoint.Port == 0 {
+		return "any port"
+	}
+	return strconv.Itoa(endpoint.Port)
+}
+
+// dotQuote returns the given string as a quoted DOT identifier. Newlines are kept as DOT's "\n" line breaks.
+func dotQuote(str string) string {
+	str = strings.ReplaceAll(str, `\`, `\\`)
+	str = strings.ReplaceAll(str, `"`, `\"`)
+	str = strings.ReplaceAll(str, "\n", `\n`)
+	return `"` + str + `"`
+}
+
+// mermaidQuote returns the given string as a quoted Mermaid label. Newlines are converted into line breaks.
+func mermaidQuote(str string) string {
+	str = strings.ReplaceAll(str, `"`, "#quot;")
+	str = strings.ReplaceAll(str, "\n", "<br/>")
+	return `"` + str + `"`
+}
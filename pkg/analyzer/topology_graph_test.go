@@ -0,0 +1,50 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionsToDOT(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "same_named_workloads")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	dot := ConnectionsToDOT(conns)
+	require.True(t, strings.HasPrefix(dot, "digraph topology {\n"))
+	require.Contains(t, dot, "\tsubgraph \"cluster_team-a\" {\n\t\tlabel=\"team-a\"\n"+
+		"\t\tn0 [label=\"backend\\n(Deployment)\"]\n\t\tn1 [label=\"backend\\n(StatefulSet)\"]\n")
+	require.Contains(t, dot, "\tn0 -> n1 [label=\"backend-db: 5432\"]\n")
+	require.Contains(t, dot, "\tn2 -> n0 [label=\"backend: 8080\"]\n")
+	require.Contains(t, dot, "\tn3 -> n0 [label=\"backend: 8080\"]\n")
+	require.Contains(t, dot, "\tn5 -> n4 [label=\"backend: 8080\"]\n") // team-b's frontend only connects to team-b's backend
+	require.Equal(t, 4, strings.Count(dot, "->"))
+}
+
+func TestConnectionsToMermaidExposedServices(t *testing.T) {
+	dirPath := filepath.Join(getTestsDir(), "bookinfo")
+	synthesizer := NewPoliciesSynthesizer()
+	conns, err := synthesizer.ConnectionsFromFolderPath(dirPath)
+	require.Nilf(t, err, "expected no fatal errors, but got %v", err)
+
+	mermaid := ConnectionsToMermaid(conns)
+	require.True(t, strings.HasPrefix(mermaid, "flowchart LR\n"))
+	require.Contains(t, mermaid, "([\"external/ingress\"])")
+	require.Contains(t, mermaid, "-.->|\"productpage: 9080\"|")
+	require.Equal(t, 1, strings.Count(mermaid, "external/ingress")) // a single node for all exposed services
+}
+
+func TestGraphLabelsQuoting(t *testing.T) {
+	require.Equal(t, `"a\"b\\c\nd"`, dotQuote("a\"b\\c\nd"))
+	require.Equal(t, `"a#quot;b<br/>c"`, mermaidQuote("a\"b\nc"))
+}
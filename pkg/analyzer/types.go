@@ -7,18 +7,89 @@ SPDX-License-Identifier: Apache-2.0
 package analyzer
 
 import (
+	"slices"
+	"strconv"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 type cfgMap struct {
 	FullName string
+	FilePath string
 	Data     map[string]string
 }
 
+// secret holds the decoded data of a K8s Secret. Its values must never be logged or written to the output.
+type secret struct {
+	FullName string
+	FilePath string
+	Data     map[string]string
+}
+
+// cfgMapRef is a reference to a whole ConfigMap (or Secret) from a workload
+type cfgMapRef struct {
+	Name       string
+	Containers []ContainerRef // the containers using the ConfigMap (either through envFrom or by mounting it as a volume)
+	optional   bool           // the ConfigMap need not exist (the reference is marked "optional")
+}
+
+// cfgMapKeyRef is a reference to a specific key in a ConfigMap (or Secret) from a workload
 type cfgMapKeyRef struct {
-	Name string
-	Key  string
+	Name      string
+	Key       string
+	EnvName   string // the name of the env var taking its value from the key
+	Container ContainerRef
+	optional  bool // the ConfigMap and the key need not exist (the reference is marked "optional")
+}
+
+// containerEnv holds what is needed for expanding "$(VAR)" references in a container's env values, args and command.
+// Expansion is deferred until all ConfigMaps and Secrets are known, because variables may take their values from them.
+type containerEnv struct {
+	Container  ContainerRef
+	EnvFrom    []corev1.EnvFromSource
+	Env        []corev1.EnvVar
+	ArgsAndCmd []fieldValue // args and command entries which refer to variables
+}
+
+// fieldValue is a value in a container's spec, together with its location (see AddressEvidence.Location)
+type fieldValue struct {
+	location string
+	value    string
+}
+
+// ContainerType distinguishes between the different kinds of containers a pod may run
+type ContainerType string
+
+const (
+	AppContainer       ContainerType = "container" // AppContainer is a regular container, running for the pod's whole lifetime
+	InitContainer      ContainerType = "init"      // InitContainer runs to completion before app containers start
+	SidecarContainer   ContainerType = "sidecar"   // SidecarContainer is a native sidecar (an init container that keeps running)
+	EphemeralContainer ContainerType = "ephemeral" // EphemeralContainer is a temporary container, e.g., for debugging
+)
+
+// ContainerRef identifies a container within a workload's pod spec
+type ContainerRef struct {
+	Name string        `json:"name,omitempty"`
+	Type ContainerType `json:"type,omitempty"`
+}
+
+// isStartupOnly returns true if the container only runs during the pod's startup
+func (cr *ContainerRef) isStartupOnly() bool {
+	return cr.Type == InitContainer
+}
+
+// AddressEvidence describes where a network address, on which a connection is based, was found.
+// For values taken from Secrets, RawValue and MatchedAddress are never set, so Secret values are not exposed.
+type AddressEvidence struct {
+	FilePath       string       `json:"filepath,omitempty"`        // the file of the workload, or of the ConfigMap holding the value
+	Container      ContainerRef `json:"container"`                 // the container in which the address was found (if any)
+	Location       string       `json:"location"`                  // e.g., "env DB_HOST", "args[2]" or "ConfigMap app-config, key DB_URL"
+	RawValue       string       `json:"raw_value,omitempty"`       // the value, as written in the manifest
+	MatchedAddress string       `json:"matched_address,omitempty"` // the address form which was matched, e.g., "orders.shop:8080"
+	fromSecret     bool
 }
 
 // Resource is an abstraction of a k8s workload resource (e.g., pod, deployment).
@@ -34,13 +105,131 @@ type Resource struct {
 		Image              struct {
 			ID string `json:"id,omitempty"`
 		} `json:"image"`
-		NetworkAddrs     []string
-		ConfigMapRefs    []string       `json:"-"`
-		ConfigMapKeyRefs []cfgMapKeyRef `json:"-"`
-		UsedPorts        []SvcNetworkAttr
+		NetworkAddrs          []string
+		NetworkAddrContainers map[string][]ContainerRef    `json:"-"` // for each network address, the containers it was found in
+		addrEvidence          map[string][]AddressEvidence // for each network address, where it was found
+		ConfigMapRefs         []cfgMapRef                  `json:"-"`
+		ConfigMapKeyRefs      []cfgMapKeyRef               `json:"-"`
+		SecretRefs            []cfgMapRef                  `json:"-"`
+		SecretKeyRefs         []cfgMapKeyRef               `json:"-"`
+		secretNetworkAddrs    []string                     // network addresses found in Secrets - never written to the output
+		envsToExpand          []*containerEnv              // containers with values that refer to env vars, expanded later
+		ContainerPorts        []corev1.ContainerPort       `json:"-"` // the ports declared by the app and sidecar containers
+		UsedPorts             []SvcNetworkAttr
+		usedByContainers      []ContainerRef         // for a connection source - the containers in which the target's address was found
+		evidence              []AddressEvidence      // for a connection source - where the target's address was found
+		scrapedPorts          []SvcNetworkAttr       // the container ports scraped by PodMonitors (exposed to the Prometheus pods)
+		podHostname           string                 // the pods' hostname, if set explicitly in the pod spec
+		podSubdomain          string                 // the (headless) service giving the pods DNS names (StatefulSet's serviceName)
+		matchedAddrs          map[string]bool        // the network addresses which matched some in-cluster service
+		ignoredEnvs           []string               // env vars whose values are not scanned for network addresses
+		annotationErrors      []*FileProcessingError // malformed workload annotations, reported when the resource is accumulated
 	} `json:"resource,omitempty"`
 }
 
+// addNetworkAddr adds the given network address to the resource, and records where it was found
+func (r1 *Resource) addNetworkAddr(netAddr string, evidence ...AddressEvidence) {
+	r1.Resource.NetworkAddrs = append(r1.Resource.NetworkAddrs, netAddr)
+	r1.addNetworkAddrEvidence(netAddr, evidence)
+}
+
+// addSecretNetworkAddr adds a network address which was found in a Secret.
+// Such addresses are used for discovering connections, but are not part of the resource's output.
+func (r1 *Resource) addSecretNetworkAddr(netAddr string, evidence ...AddressEvidence) {
+	r1.Resource.secretNetworkAddrs = append(r1.Resource.secretNetworkAddrs, netAddr)
+	for i := range evidence {
+		evidence[i].RawValue = ""
+		evidence[i].fromSecret = true
+	}
+	r1.addNetworkAddrEvidence(netAddr, evidence)
+}
+
+// allNetworkAddrs returns all the network addresses found for the resource, including those found in Secrets
+func (r1 *Resource) allNetworkAddrs() []string {
+	return append(slices.Clip(r1.Resource.NetworkAddrs), r1.Resource.secretNetworkAddrs...)
+}
+
+func (r1 *Resource) addNetworkAddrEvidence(netAddr string, evidence []AddressEvidence) {
+	if r1.Resource.NetworkAddrContainers == nil {
+		r1.Resource.NetworkAddrContainers = map[string][]ContainerRef{}
+		r1.Resource.addrEvidence = map[string][]AddressEvidence{}
+	}
+	for i := range evidence {
+		if evidence[i].FilePath == "" {
+			evidence[i].FilePath = r1.Resource.FilePath
+		}
+		container := evidence[i].Container
+		if container.Name != "" && !slices.Contains(r1.Resource.NetworkAddrContainers[netAddr], container) {
+			r1.Resource.NetworkAddrContainers[netAddr] = append(r1.Resource.NetworkAddrContainers[netAddr], container)
+		}
+		if !slices.Contains(r1.Resource.addrEvidence[netAddr], evidence[i]) {
+			r1.Resource.addrEvidence[netAddr] = append(r1.Resource.addrEvidence[netAddr], evidence[i])
+		}
+	}
+}
+
+// evidenceOf returns where the given network address of the resource was found, marking the address as the matched one
+func (r1 *Resource) evidenceOf(netAddr string) []AddressEvidence {
+	res := []AddressEvidence{}
+	for _, evidence := range r1.Resource.addrEvidence[netAddr] {
+		if !evidence.fromSecret {
+			evidence.MatchedAddress = netAddr
+		}
+		res = append(res, evidence)
+	}
+	return res
+}
+
+// hasPodHostname returns true if one of the resource's pods may have the given hostname.
+// StatefulSet pods are named (and hence have hostnames) "<statefulset-name>-<ordinal>".
+func (r1 *Resource) hasPodHostname(hostname string) bool {
+	if r1.Resource.Kind == statefulSet {
+		ordinal, found := strings.CutPrefix(hostname, r1.Resource.Name+"-")
+		if !found || ordinal == "" {
+			return false
+		}
+		_, err := strconv.ParseUint(ordinal, 10, 32)
+		return err == nil
+	}
+	return hostname != "" && hostname == r1.Resource.podHostname
+}
+
+// declaredPort returns the port, declared by one of the resource's containers, which the given service port targets.
+// If no container declares the target port, nil is returned.
+func (r1 *Resource) declaredPort(svcPort *SvcNetworkAttr) *corev1.ContainerPort {
+	targetPort := svcPort.TargetPort
+	if targetPort.Type == intstr.Int && targetPort.IntVal == 0 {
+		targetPort = intstr.FromInt(svcPort.Port) // targetPort defaults to the service port
+	}
+	for i := range r1.Resource.ContainerPorts {
+		containerPort := &r1.Resource.ContainerPorts[i]
+		if protocolOrDefault(containerPort.Protocol) != protocolOrDefault(svcPort.Protocol) {
+			continue
+		}
+		if (targetPort.Type == intstr.String && containerPort.Name == targetPort.StrVal) ||
+			(targetPort.Type == intstr.Int && containerPort.ContainerPort == targetPort.IntVal) {
+			return containerPort
+		}
+	}
+	return nil
+}
+
+// protocolOrDefault returns the given protocol, or TCP (the default protocol in K8s) if no protocol is given
+func protocolOrDefault(protocol corev1.Protocol) corev1.Protocol {
+	if protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return protocol
+}
+
+// markMatchedAddr records that the given network address of the resource matched some in-cluster service
+func (r1 *Resource) markMatchedAddr(netAddr string) {
+	if r1.Resource.matchedAddrs == nil {
+		r1.Resource.matchedAddrs = map[string]bool{}
+	}
+	r1.Resource.matchedAddrs[netAddr] = true
+}
+
 func (r1 *Resource) equals(r2 *Resource) bool {
 	return r1.Resource.Name == r2.Resource.Name &&
 		r1.Resource.Namespace == r2.Resource.Namespace &&
@@ -54,6 +243,25 @@ type SvcNetworkAttr struct {
 	TargetPort      intstr.IntOrString `json:"target_port,omitempty"`
 	Protocol        corev1.Protocol    `json:"protocol,omitempty"`
 	exposeToCluster bool
+	exposedBy       []portExposer // what exposes the port to the cluster (only set if exposeToCluster is set)
+}
+
+// portExposer is what exposes a service port to the cluster, determining which pods may connect to it.
+// The zero value (used for hints) allows any pod in the cluster.
+type portExposer struct {
+	ingressController bool          // an Ingress or a Route, so the port is reached through the ingress controller pods
+	gateway           string        // a Gateway API route, so the port is reached through this Gateway ("<namespace>/<name>")
+	istioGateway      string        // an Istio VirtualService, so the port is reached through this Istio Gateway ("<namespace>/<name>")
+	istioGatewayPods  *istioGateway // the Istio Gateway, if found among the analyzed resources (its selector determines its pods)
+	prometheus        bool          // a ServiceMonitor, a PodMonitor or scrape annotations, so the port is scraped by Prometheus
+}
+
+// expose marks the port as exposed to the cluster by the given exposer
+func (port *SvcNetworkAttr) expose(exposer portExposer) {
+	port.exposeToCluster = true
+	if !slices.Contains(port.exposedBy, exposer) {
+		port.exposedBy = append(port.exposedBy, exposer)
+	}
 }
 
 // Service is used to store information about a K8s Service
@@ -66,26 +274,58 @@ type Service struct {
 		FilePath         string             `json:"filepath,omitempty"`
 		Kind             string             `json:"kind,omitempty"`
 		Network          []SvcNetworkAttr   `json:"network,omitempty"`
+		ExternalName     string             `json:"external_name,omitempty"` // the external host of an ExternalName service
 		ExposeExternally bool               `json:"-"`
+		headless         bool               // a service with "clusterIP: None" - its pods also get individual DNS names
+		exposedByHint    bool               // the service is exposed by a hint (see WithHints())
+		labels           map[string]string  // the labels of the service itself (e.g., for matching ServiceMonitors)
+		endpoints        *serviceEndpoints  // for a selector-less service - its endpoints, as given by EndpointSlices or Endpoints
 	} `json:"resource,omitempty"`
 }
 
+// ExternalEndpoint is a network endpoint outside the cluster, which a workload connects to.
+// Exactly one of Host (an external DNS name) and IPBlock (an IP address or a CIDR, in CIDR notation) is set.
+type ExternalEndpoint struct {
+	Host    string `json:"host,omitempty"`
+	IPBlock string `json:"ip_block,omitempty"`
+	Port    int    `json:"port,omitempty"`
+}
+
 // Connections represents a connection from a source workload to a target workload using via a service.
+// For an "external egress" connection, ExternalTarget is set instead of Target. Link is only set for such a connection
+// if the source reaches the external target through an ExternalName service or a selector-less service.
+// External egress connections are only returned by the ConnectionsFrom*() methods if WithExternalConnections() is used.
+// SourceContainers lists the containers of the source workload in which the target's address was found.
+// StartupOnly is set if all these containers are init containers, i.e., the connection is only required during startup.
+// Evidence is only set when explanations are requested (see WithExplanations()).
+// FromHint is set for connections which were declared in a hints file, or are due to a service exposed by a hints file.
 type Connections struct {
-	Source *Resource `json:"source,omitempty"`
-	Target *Resource `json:"target"`
-	Link   *Service  `json:"link"`
+	Source           *Resource         `json:"source,omitempty"`
+	Target           *Resource         `json:"target"`
+	Link             *Service          `json:"link"`
+	ExternalTarget   *ExternalEndpoint `json:"external_target,omitempty"`
+	SourceContainers []ContainerRef    `json:"source_containers,omitempty"`
+	StartupOnly      bool              `json:"startup_only,omitempty"`
+	Evidence         []AddressEvidence `json:"evidence,omitempty"`
+	FromHint         bool              `json:"from_hint,omitempty"`
 }
 
 // A map from namespaces to a map of service names in each namespaces, which we want to expose within the cluster.
-// For each service we hold the ports that should be exposed
-type servicesToExpose map[string]map[string][]*intstr.IntOrString
+// For each service we hold the ports that should be exposed, and what exposes them
+type servicesToExpose map[string]map[string][]exposedPort
+
+type exposedPort struct {
+	port      *intstr.IntOrString // nil means all the service ports
+	exposer   portExposer
+	route     *gatewayRoute              // the Gateway API route exposing the port (nil for Ingress and Route resources)
+	parentRef *gatewayv1.ParentReference // the Gateway the route is attached to (nil if the exposer is not a Gateway)
+}
 
-func (ste servicesToExpose) appendPort(namespace, svcName string, port *intstr.IntOrString) {
+func (ste servicesToExpose) appendPort(namespace, svcName string, port *exposedPort) {
 	svcPortsMap, ok := ste[namespace]
 	if !ok {
-		ste[namespace] = map[string][]*intstr.IntOrString{}
+		ste[namespace] = map[string][]exposedPort{}
 		svcPortsMap = ste[namespace]
 	}
-	svcPortsMap[svcName] = append(svcPortsMap[svcName], port)
+	svcPortsMap[svcName] = append(svcPortsMap[svcName], *port)
 }
@@ -0,0 +1,126 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"strings"
+)
+
+// envVarValue is the value of a container's env var, as it is known during the analysis
+type envVarValue struct {
+	value      string
+	fromSecret bool // values taken from Secrets must not be written to the output
+}
+
+// hasVarRef returns true if the given string contains a Kubernetes-style "$(VAR)" reference
+func hasVarRef(val string) bool {
+	return strings.Contains(val, "$(")
+}
+
+// expandVarRefs expands "$(VAR)" references in the containers of the given workload (see deferVarExpansion()),
+// and adds the network addresses found in the expanded values to the workload.
+// Variables are resolved the same way the kubelet does: first all envFrom sources, then the env list in order,
+// where each env value may only refer to variables defined before it. Args and command may refer to all variables.
+func expandVarRefs(res *Resource, cfgMapsByName, secretsByName map[string]map[string]string) {
+	for _, cEnv := range res.Resource.envsToExpand {
+		vars := map[string]envVarValue{}
+		for i := range cEnv.EnvFrom {
+			envFrom := &cEnv.EnvFrom[i]
+			if envFrom.ConfigMapRef != nil {
+				addVarsFromData(vars, envFrom.Prefix, cfgMapsByName[res.Resource.Namespace+"/"+envFrom.ConfigMapRef.Name], false)
+			}
+			if envFrom.SecretRef != nil {
+				addVarsFromData(vars, envFrom.Prefix, secretsByName[res.Resource.Namespace+"/"+envFrom.SecretRef.Name], true)
+			}
+		}
+
+		for i := range cEnv.Env {
+			e := &cEnv.Env[i]
+			switch {
+			case e.ValueFrom == nil:
+				val := expandVarRefsInStr(e.Value, vars)
+				vars[e.Name] = val
+				if hasVarRef(e.Value) && !res.isIgnoredEnv(e.Name) {
+					addExpandedNetworkAddrs(res, val, AddressEvidence{Container: cEnv.Container, Location: envLocation(e.Name), RawValue: e.Value})
+				}
+			case e.ValueFrom.ConfigMapKeyRef != nil:
+				keyRef := e.ValueFrom.ConfigMapKeyRef
+				addVarFromDataKey(vars, e.Name, cfgMapsByName[res.Resource.Namespace+"/"+keyRef.Name], keyRef.Key, false)
+			case e.ValueFrom.SecretKeyRef != nil:
+				keyRef := e.ValueFrom.SecretKeyRef
+				addVarFromDataKey(vars, e.Name, secretsByName[res.Resource.Namespace+"/"+keyRef.Name], keyRef.Key, true)
+			}
+			// variables taking their values from other sources (e.g., fieldRef) are unknown, so references to them are kept as is
+		}
+
+		for _, val := range cEnv.ArgsAndCmd {
+			evidence := AddressEvidence{Container: cEnv.Container, Location: val.location, RawValue: val.value}
+			addExpandedNetworkAddrs(res, expandVarRefsInStr(val.value, vars), evidence)
+		}
+	}
+}
+
+// addVarsFromData defines a variable for each key in the given ConfigMap/Secret data (a nil data is a missing ConfigMap/Secret)
+func addVarsFromData(vars map[string]envVarValue, prefix string, data map[string]string, fromSecret bool) {
+	for k, v := range data {
+		vars[prefix+k] = envVarValue{value: v, fromSecret: fromSecret}
+	}
+}
+
+func addVarFromDataKey(vars map[string]envVarValue, name string, data map[string]string, key string, fromSecret bool) {
+	if v, ok := data[key]; ok {
+		vars[name] = envVarValue{value: v, fromSecret: fromSecret}
+	}
+}
+
+// addExpandedNetworkAddrs adds the network addresses found in an expanded value.
+// The evidence's raw value is the value before the expansion.
+func addExpandedNetworkAddrs(res *Resource, val envVarValue, evidence AddressEvidence) {
+	for _, netAddr := range networkAddressesFromStr(val.value) {
+		if val.fromSecret {
+			res.addSecretNetworkAddr(netAddr, evidence)
+		} else {
+			res.addNetworkAddr(netAddr, evidence)
+		}
+	}
+}
+
+// expandVarRefsInStr expands all "$(VAR)" references in the given string, following Kubernetes' rules:
+// "$$" is an escaped "$" and references to undefined variables are left unchanged.
+// The result is marked as coming from a Secret if any of the variables it refers to is.
+func expandVarRefsInStr(val string, vars map[string]envVarValue) envVarValue {
+	var buf strings.Builder
+	fromSecret := false
+	for i := 0; i < len(val); i++ {
+		if val[i] != '$' || i+1 == len(val) {
+			buf.WriteByte(val[i])
+			continue
+		}
+		switch val[i+1] {
+		case '$':
+			buf.WriteByte('$')
+			i++
+		case '(':
+			refLen := strings.IndexByte(val[i+2:], ')')
+			if refLen < 0 { // unterminated reference - take the rest of the string as is
+				buf.WriteString(val[i:])
+				return envVarValue{value: buf.String(), fromSecret: fromSecret}
+			}
+			varName := val[i+2 : i+2+refLen]
+			if varVal, ok := vars[varName]; ok {
+				buf.WriteString(varVal.value)
+				fromSecret = fromSecret || varVal.fromSecret
+			} else {
+				buf.WriteString(val[i : i+3+refLen])
+			}
+			i += 2 + refLen
+		default:
+			buf.WriteByte(val[i])
+		}
+	}
+	return envVarValue{value: buf.String(), fromSecret: fromSecret}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandVarRefsInStr(t *testing.T) {
+	vars := map[string]envVarValue{
+		"HOST":     {value: "backend"},
+		"PORT":     {value: "8080"},
+		"PASSWORD": {value: "s3cr3t", fromSecret: true},
+	}
+	tests := []struct {
+		val        string
+		expected   string
+		fromSecret bool
+	}{
+		{"$(HOST):$(PORT)", "backend:8080", false},
+		{"--backend=http://$(HOST):$(PORT)/api", "--backend=http://backend:8080/api", false},
+		{"$(UNDEFINED):$(PORT)", "$(UNDEFINED):8080", false},
+		{"$$(HOST)", "$(HOST)", false},
+		{"$$$(HOST)", "$backend", false},
+		{"$HOST", "$HOST", false},
+		{"$(HOST", "$(HOST", false},
+		{"price$", "price$", false},
+		{"user:$(PASSWORD)@$(HOST)", "user:s3cr3t@backend", true},
+	}
+	for _, tt := range tests {
+		res := expandVarRefsInStr(tt.val, vars)
+		require.Equal(t, tt.expected, res.value, tt.val)
+		require.Equal(t, tt.fromSecret, res.fromSecret, tt.val)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020- IBM Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package analyzer
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Workload annotations, allowing application teams to correct the discovered connectivity.
+// Both annotations hold comma-separated lists, and may be set on the workload object and/or on its pod template.
+const (
+	// DependsOnAnnotation lists network addresses the workload connects to, in the form "<host>[:<port>]",
+	// e.g., "orders.shop:8080,redis". These are added to the workload's network addresses.
+	DependsOnAnnotation = "topology.np-guard/depends-on"
+	// IgnoreEnvAnnotation lists env vars whose values should not be scanned for network addresses, e.g., "DEBUG_URL".
+	IgnoreEnvAnnotation = "topology.np-guard/ignore-env"
+)
+
+// parseTopologyAnnotations reads the workload annotations from the given object and its pod template.
+// Env vars to ignore are set in the given resource, and the addresses it depends on are returned.
+// Malformed entries are skipped, and returned as errors.
+func parseTopologyAnnotations(podSpec *v1.PodTemplateSpec, obj metaV1.Object, resourceCtx *Resource) (
+	[]string, []*FileProcessingError) {
+	errs := []*FileProcessingError{}
+	dependencies := []string{}
+	for _, annotations := range []map[string]string{obj.GetAnnotations(), podSpec.Annotations} {
+		for _, entry := range annotationEntries(annotations, DependsOnAnnotation) {
+			if !isValidDependency(entry) {
+				errs = append(errs, malformedAnnotation(DependsOnAnnotation, entry, resourceCtx.Resource.Name, resourceCtx.Resource.FilePath))
+			} else if !slices.Contains(dependencies, entry) {
+				dependencies = append(dependencies, entry)
+			}
+		}
+		for _, entry := range annotationEntries(annotations, IgnoreEnvAnnotation) {
+			if len(validation.IsEnvVarName(entry)) > 0 {
+				errs = append(errs, malformedAnnotation(IgnoreEnvAnnotation, entry, resourceCtx.Resource.Name, resourceCtx.Resource.FilePath))
+			} else if !slices.Contains(resourceCtx.Resource.ignoredEnvs, entry) {
+				resourceCtx.Resource.ignoredEnvs = append(resourceCtx.Resource.ignoredEnvs, entry)
+			}
+		}
+	}
+	return dependencies, errs
+}
+
+// annotationEntries splits the value of the given annotation into its (comma-separated) entries
+func annotationEntries(annotations map[string]string, annotation string) []string {
+	value, ok := annotations[annotation]
+	if !ok {
+		return nil
+	}
+	entries := strings.Split(value, ",")
+	for i := range entries {
+		entries[i] = strings.TrimSpace(entries[i])
+	}
+	return entries
+}
+
+// isValidDependency checks that the given entry of the depends-on annotation is in the form "<host>[:<port>]",
+// where host is a valid DNS name (possibly with a trailing dot), and port is a valid port number
+func isValidDependency(entry string) bool {
+	host := entry
+	if strings.Contains(entry, ":") {
+		var port string
+		var err error
+		host, port, err = net.SplitHostPort(entry)
+		if err != nil || !isValidPortNum(port) {
+			return false
+		}
+	}
+	return len(validation.IsDNS1123Subdomain(strings.TrimSuffix(host, "."))) == 0
+}
+
+// addDependencies adds the addresses from the depends-on annotation to the resource's network addresses
+func addDependencies(dependencies []string, resourceCtx *Resource) {
+	for _, dependency := range dependencies {
+		resourceCtx.addNetworkAddr(dependency, AddressEvidence{Location: "annotation " + DependsOnAnnotation, RawValue: dependency})
+	}
+}
+
+// isIgnoredEnv returns true if the given env var should not be scanned for network addresses (see IgnoreEnvAnnotation)
+func (r1 *Resource) isIgnoredEnv(envName string) bool {
+	return slices.Contains(r1.Resource.ignoredEnvs, envName)
+}